@@ -24,7 +24,6 @@ import (
 	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
 	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
 	"go.uber.org/zap"
-	"golang.org/x/exp/rand"
 )
 
 const port = 18080
@@ -37,6 +36,8 @@ type Opts struct {
 	Database *sql.DB
 	// Logger is the logger to use for logging.
 	Logger *zap.Logger
+	// OpenAPISpec is the OpenAPI specification document used to validate requests.
+	OpenAPISpec []byte
 }
 
 // Application instance.
@@ -45,6 +46,7 @@ type App struct {
 	database *sql.DB
 	logger   *zap.Logger
 	server   *http.Server
+	handlers *server.Handler
 }
 
 // NewApp creates and instance of the application.
@@ -60,81 +62,228 @@ func NewApp(opts Opts) (*App, error) {
 		return nil, fmt.Errorf("unable to create handlers: %w", err)
 	}
 
+	// Validate requests against the OpenAPI specification before they reach the handlers
+	validator, err := server.NewValidator(opts.OpenAPISpec, opts.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request validator: %w", err)
+	}
+
+	// Optionally validate responses as well, so contract breaks are caught at the source
+	switch opts.Config.ResponseValidation {
+	case config.ResponseValidationDisabled:
+	case config.ResponseValidationLog:
+		router.Use(validator.ResponseMiddleware(false))
+	case config.ResponseValidationEnforce:
+		router.Use(validator.ResponseMiddleware(true))
+	default:
+		return nil, fmt.Errorf("invalid response validation mode %q", opts.Config.ResponseValidation)
+	}
+
+	// Limit the rate of the requests of every client IP, authenticate every
+	// request except for the public routes, limit the rate of the requests of
+	// every client, then validate them.
+	// Each route is authorized separately, by the permission it requires.
+	router.Use(handlers.IPRateLimitMiddleware("/health", "/metrics"))
+	router.Use(handlers.AuthMiddleware("/v1/token", "/v1/token/refresh", "/.well-known/jwks.json", "/health",
+		"/metrics"))
+	router.Use(handlers.RateLimitMiddleware("/health", "/metrics"))
+	router.Use(validator.Middleware)
+	// Inject faults in the requests for chaos testing, when enabled.
+	router.Use(handlers.FaultInjectionMiddleware)
+
+	// Report the health of the server
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		handlers.HealthHandler(w, r)
+	}).Methods("GET")
+
+	// Expose the metrics of the server
+	router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handlers.MetricsHandler(w, r)
+	}).Methods("GET")
+
+	// Publish the keys used to verify tokens
+	router.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		handlers.JWKSHandler(w, r)
+	}).Methods("GET")
+
 	// Register the /token endpoint
 	router.HandleFunc("/v1/token", func(w http.ResponseWriter, r *http.Request) {
 		handlers.CreateTokenHandler(w, r)
 	}).Methods("POST")
 
+	// Renew a token with a refresh token
+	router.HandleFunc("/v1/token/refresh", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RefreshTokenHandler(w, r)
+	}).Methods("POST")
+
+	// Revoke a token before it expires, available to every authenticated user
+	router.HandleFunc("/v1/token/revoke", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeTokenHandler(w, r)
+	}).Methods("POST")
+
+	// Register endpoints for managing API keys, available to every authenticated user
+	// Create a new API key for the caller
+	router.HandleFunc("/v1/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateAPIKeyHandler(w, r)
+	}).Methods("POST")
+
+	// List the API keys of the caller
+	router.HandleFunc("/v1/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ListAPIKeysHandler(w, r)
+	}).Methods("GET")
+
+	// Revoke an API key
+	router.HandleFunc("/v1/api-keys/{keyId}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeAPIKeyHandler(w, r)
+	}).Methods("DELETE")
+
+	// Search services and service versions
+	router.HandleFunc("/v1/search",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.SearchHandler(w, r)
+		})).Methods("GET")
+
+	// Register endpoints for managing users
+	// Create a new user
+	router.HandleFunc("/v1/users",
+		handlers.Authorize(server.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.CreateUserHandler(w, r)
+		})).Methods("POST")
+
+	// List all users
+	router.HandleFunc("/v1/users",
+		handlers.Authorize(server.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListUsersHandler(w, r)
+		})).Methods("GET")
+
+	// Update or disable a specific user by ID
+	router.HandleFunc("/v1/users/{userId}",
+		handlers.Authorize(server.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.UpdateUserHandler(w, r)
+		})).Methods("PATCH")
+
+	// List the login audit trail
+	router.HandleFunc("/v1/login-attempts",
+		handlers.Authorize(server.PermissionUsersManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListLoginAttemptsHandler(w, r)
+		})).Methods("GET")
+
+	// List the audit log of catalog and credential changes
+	router.HandleFunc("/v1/audit",
+		handlers.Authorize(server.PermissionAuditRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListAuditEventsHandler(w, r)
+		})).Methods("GET")
+
+	// Inspect and change the faults injected in requests
+	router.HandleFunc("/v1/admin/faults",
+		handlers.Authorize(server.PermissionFaultsManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.GetFaultsHandler(w, r)
+		})).Methods("GET")
+
+	router.HandleFunc("/v1/admin/faults",
+		handlers.Authorize(server.PermissionFaultsManage, func(w http.ResponseWriter, r *http.Request) {
+			handlers.UpdateFaultsHandler(w, r)
+		})).Methods("PUT")
+
 	// Register endpoints for services
 	// Create a new service
-	router.HandleFunc("/v1/services", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CreateServiceHandler(w, r)
-	}).Methods("POST")
+	router.HandleFunc("/v1/services",
+		handlers.Authorize(server.PermissionCatalogWrite, handlers.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+			handlers.CreateServiceHandler(w, r)
+		}))).Methods("POST")
 
 	// List all services
-	router.HandleFunc("/v1/services", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ListServicesHandler(w, r)
-	}).Methods("GET")
+	router.HandleFunc("/v1/services",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListServicesHandler(w, r)
+		})).Methods("GET")
 
 	// Get a specific service by ID
-	router.HandleFunc("/v1/services/{serviceId}", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetServiceHandler(w, r)
-	}).Methods("GET")
+	router.HandleFunc("/v1/services/{serviceId}",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.GetServiceHandler(w, r)
+		})).Methods("GET")
 
 	// Update a specific service by ID
-	router.HandleFunc("/v1/services/{serviceId}", func(w http.ResponseWriter, r *http.Request) {
-		handlers.UpdateServiceHandler(w, r)
-	}).Methods("PATCH")
+	router.HandleFunc("/v1/services/{serviceId}",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.UpdateServiceHandler(w, r)
+		})).Methods("PATCH")
 
 	// Delete a specific service by ID
-	router.HandleFunc("/v1/services/{serviceId}", func(w http.ResponseWriter, r *http.Request) {
-		handlers.DeleteServiceHandler(w, r)
-	}).Methods("DELETE")
+	router.HandleFunc("/v1/services/{serviceId}",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.DeleteServiceHandler(w, r)
+		})).Methods("DELETE")
+
+	// Restore a deleted service by ID
+	router.HandleFunc("/v1/services/{serviceId}:restore",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.RestoreServiceHandler(w, r)
+		})).Methods("POST")
+
+	// List the revisions of a specific service and of its versions
+	router.HandleFunc("/v1/services/{serviceId}/history",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ServiceHistoryHandler(w, r)
+		})).Methods("GET")
+
+	// Revert a specific service to a prior revision
+	router.HandleFunc("/v1/services/{serviceId}/history/{revision}:revert",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.RevertServiceHandler(w, r)
+		})).Methods("POST")
 
 	// Register endpoints for service versions
 	// Create a new version for a specific service
-	router.HandleFunc("/v1/services/{serviceId}/versions", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CreateServiceVersionHandler(w, r)
-	}).Methods("POST")
+	router.HandleFunc("/v1/services/{serviceId}/versions",
+		handlers.Authorize(server.PermissionCatalogWrite, handlers.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+			handlers.CreateServiceVersionHandler(w, r)
+		}))).Methods("POST")
 
 	// List all versions for a specific service
-	router.HandleFunc("/v1/services/{serviceId}/versions", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ListServiceVersionsHandler(w, r)
-	}).Methods("GET")
+	router.HandleFunc("/v1/services/{serviceId}/versions",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.ListServiceVersionsHandler(w, r)
+		})).Methods("GET")
 
 	// Get a specific version by ID for a specific service
-	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetServiceVersionHandler(w, r)
-	}).Methods("GET")
+	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.GetServiceVersionHandler(w, r)
+		})).Methods("GET")
 
 	// Update a specific version by ID for a specific service
-	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}", func(w http.ResponseWriter, r *http.Request) {
-		handlers.UpdateServiceVersionHandler(w, r)
-	}).Methods("PATCH")
+	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.UpdateServiceVersionHandler(w, r)
+		})).Methods("PATCH")
 
 	// Delete a specific version by ID for a specific service
-	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}", func(w http.ResponseWriter, r *http.Request) {
-		// 20% chance to introduce a timeout for testing.
-		if rand.Float64() < 0.2 {
-			// Create a context with a timeout; simulate a long timeout for testing.
-			timeoutDuration := opts.Config.RequestTimeout * 1000
-			ctx, cancel := context.WithTimeout(r.Context(), timeoutDuration)
-			_ = r.WithContext(ctx)
-			defer cancel()
-
-			// Simulate a request that hangs indefinitely unless canceled.
-			<-ctx.Done()
-			http.Error(w, "Request timed out", http.StatusGatewayTimeout)
-			return
-		}
+	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.DeleteServiceVersionHandler(w, r)
+		})).Methods("DELETE")
 
-		// No timeout introduced, proceed with the handler normally.
-		handlers.DeleteServiceVersionHandler(w, r)
-	}).Methods("DELETE")
+	// Restore a deleted version by ID for a specific service
+	router.HandleFunc("/v1/services/{serviceId}/versions/{versionId}:restore",
+		handlers.Authorize(server.PermissionCatalogWrite, func(w http.ResponseWriter, r *http.Request) {
+			handlers.RestoreServiceVersionHandler(w, r)
+		})).Methods("POST")
+
+	// Summarise the changes of the catalog between two instants
+	router.HandleFunc("/v1/catalog/diff",
+		handlers.Authorize(server.PermissionCatalogRead, func(w http.ResponseWriter, r *http.Request) {
+			handlers.CatalogDiffHandler(w, r)
+		})).Methods("GET")
 
-	// Create an HTTP server with the router
-	server := &http.Server{
-		Handler:           router,
+	// Respond to unknown routes and unsupported methods with JSON errors
+	router.NotFoundHandler = server.NotFoundHandler()
+	router.MethodNotAllowedHandler = server.MethodNotAllowedHandler()
+
+	// Create an HTTP server with the router, tagging every request with an ID
+	httpServer := &http.Server{
+		Handler:           server.RequestIDMiddleware(router),
 		ReadTimeout:       opts.Config.RequestTimeout,
 		ReadHeaderTimeout: opts.Config.RequestTimeout,
 		WriteTimeout:      opts.Config.RequestTimeout,
@@ -144,7 +293,8 @@ func NewApp(opts Opts) (*App, error) {
 		config:   opts.Config,
 		database: opts.Database,
 		logger:   opts.Logger,
-		server:   server,
+		server:   httpServer,
+		handlers: handlers,
 	}, nil
 }
 
@@ -158,6 +308,9 @@ func (a *App) Run(ctx context.Context) error {
 		}
 	}()
 
+	// Purge the services and versions deleted for too long in the background
+	go a.handlers.PurgeDeleted(ctx)
+
 	// Wait for shutdown signal
 	<-ctx.Done()
 
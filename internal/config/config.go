@@ -22,35 +22,298 @@ import (
 )
 
 const (
-	defaultJWTSecret       = "kong"
 	defaultJTWTokenTimeout = 30 * time.Minute
+	defaultRefreshTimeout  = 24 * time.Hour
+	defaultJWTIssuer       = "candidate-take-home-exercise-sdet"
+	defaultJWTAudience     = "service-catalog"
+	defaultJWTClockSkew    = 30 * time.Second
 	defaultUsername        = "kong"
 	defaultPassword        = "onward"
 	defaultRequestTimeout  = 5 * time.Second
+
+	defaultMaxFailuresPerUsername = 5
+	defaultMaxFailuresPerIP       = 20
+	defaultBaseLockout            = time.Second
+	defaultMaxLockout             = 15 * time.Minute
+	defaultFailureWindow          = 15 * time.Minute
+
+	defaultClientRequestsPerSecond = 50
+	defaultClientBurst             = 100
+	defaultIPRequestsPerSecond     = 100
+	defaultIPBurst                 = 200
+
+	defaultCacheControl = "private, no-cache"
+
+	defaultIdempotencyWindow = 24 * time.Hour
+
+	defaultPurgeAfter    = 30 * 24 * time.Hour
+	defaultPurgeInterval = time.Hour
 )
 
+// defaultRoles maps each role to the permissions it grants.
+var defaultRoles = map[string][]string{
+	"viewer": {"catalog:read"},
+	"editor": {"catalog:read", "catalog:write"},
+	"admin":  {"catalog:read", "catalog:write", "users:manage", "faults:manage", "audit:read"},
+}
+
+// Modes for validating responses against the OpenAPI specification.
+const (
+	// ResponseValidationDisabled skips response validation.
+	ResponseValidationDisabled = "disabled"
+	// ResponseValidationLog logs responses that do not match the specification.
+	ResponseValidationLog = "log"
+	// ResponseValidationEnforce replaces responses that do not match the
+	// specification with a 500 describing the mismatch.
+	ResponseValidationEnforce = "enforce"
+)
+
+// SigningKey is a key used to sign or verify JWT tokens.
+type SigningKey struct {
+	// KID identifies the key in the kid header of the tokens and in the JWKS.
+	KID string `yaml:"kid" mapstructure:"kid"`
+	// Algorithm is the signing algorithm of the key; RS256 or ES256.
+	Algorithm string `yaml:"algorithm" mapstructure:"algorithm"`
+	// PrivateKeyFile is the path to the PEM encoded private key. Keys without
+	// a private key are only used to verify tokens, e.g. after a rotation.
+	PrivateKeyFile string `yaml:"private_key_file" mapstructure:"private_key_file"`
+	// PublicKeyFile is the path to the PEM encoded public key. It is derived
+	// from the private key when omitted.
+	PublicKeyFile string `yaml:"public_key_file" mapstructure:"public_key_file"`
+}
+
+// TrustedIssuer is an external identity provider whose tokens are accepted.
+type TrustedIssuer struct {
+	// Issuer is the issuer (iss) of the tokens of the identity provider.
+	Issuer string `yaml:"issuer" mapstructure:"issuer"`
+	// JWKSURL is where the identity provider publishes the keys verifying its
+	// tokens. The keys are cached and refreshed periodically.
+	JWKSURL string `yaml:"jwks_url" mapstructure:"jwks_url"`
+	// JWKSFile is the path to a static JWKS, used instead of JWKSURL.
+	JWKSFile string `yaml:"jwks_file" mapstructure:"jwks_file"`
+	// JWKSRefreshInterval is how long the keys fetched from JWKSURL are cached.
+	JWKSRefreshInterval time.Duration `yaml:"jwks_refresh_interval" mapstructure:"jwks_refresh_interval"`
+	// Audience is the audience (aud) the tokens must be issued for.
+	Audience string `yaml:"audience" mapstructure:"audience"`
+	// UsernameClaim is the claim holding the username; sub when omitted.
+	UsernameClaim string `yaml:"username_claim" mapstructure:"username_claim"`
+	// RolesClaim is the claim holding the groups or roles of the user.
+	RolesClaim string `yaml:"roles_claim" mapstructure:"roles_claim"`
+	// RoleMapping maps the values of RolesClaim, compared case-insensitively,
+	// to the viewer, editor and admin roles. Other values are ignored.
+	RoleMapping map[string]string `yaml:"role_mapping" mapstructure:"role_mapping"`
+}
+
+// LoginProtection configures the protection of /v1/token against brute-force
+// attacks. Once a username or a client IP reaches its number of failed logins,
+// it is locked out for BaseLockout, doubled with every further failure up to
+// MaxLockout.
+type LoginProtection struct {
+	// MaxFailuresPerUsername is the number of failed logins allowed for a
+	// username before it is locked out.
+	MaxFailuresPerUsername int `yaml:"max_failures_per_username" mapstructure:"max_failures_per_username"`
+	// MaxFailuresPerIP is the number of failed logins allowed from a client IP
+	// before it is locked out.
+	MaxFailuresPerIP int `yaml:"max_failures_per_ip" mapstructure:"max_failures_per_ip"`
+	// BaseLockout is the duration of the first lockout.
+	BaseLockout time.Duration `yaml:"base_lockout" mapstructure:"base_lockout"`
+	// MaxLockout caps the duration of a lockout.
+	MaxLockout time.Duration `yaml:"max_lockout" mapstructure:"max_lockout"`
+	// FailureWindow is how long failed logins are remembered after the last one.
+	FailureWindow time.Duration `yaml:"failure_window" mapstructure:"failure_window"`
+	// TrustForwardedFor identifies clients by the first address of the
+	// X-Forwarded-For header. Only enable it behind a proxy setting the header.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" mapstructure:"trust_forwarded_for"`
+}
+
+// RateLimit is a token bucket: requests are allowed in bursts of up to Burst
+// requests, refilled at RequestsPerSecond. A rate of zero disables the limit.
+type RateLimit struct {
+	// RequestsPerSecond is the sustained rate of allowed requests.
+	RequestsPerSecond float64 `yaml:"requests_per_second" mapstructure:"requests_per_second"`
+	// Burst is the number of requests allowed at once.
+	Burst int `yaml:"burst" mapstructure:"burst"`
+}
+
+// RouteRateLimit overrides the rate limit of every client for a route.
+type RouteRateLimit struct {
+	// Method is the HTTP method of the route.
+	Method string `yaml:"method" mapstructure:"method"`
+	// Path is the path template of the route, e.g. /v1/services/{serviceId}.
+	Path string `yaml:"path" mapstructure:"path"`
+	// RequestsPerSecond is the sustained rate of allowed requests.
+	RequestsPerSecond float64 `yaml:"requests_per_second" mapstructure:"requests_per_second"`
+	// Burst is the number of requests allowed at once.
+	Burst int `yaml:"burst" mapstructure:"burst"`
+}
+
+// RateLimiting configures the rate limits of the requests. Clients are the
+// authenticated users, or the client IP of anonymous requests.
+type RateLimiting struct {
+	// Global limits the requests of all the clients together.
+	Global RateLimit `yaml:"global" mapstructure:"global"`
+	// PerClient limits the requests of every client.
+	PerClient RateLimit `yaml:"per_client" mapstructure:"per_client"`
+	// PerIP limits the requests of every client IP before they are
+	// authenticated, so that requests with invalid credentials are limited
+	// too.
+	PerIP RateLimit `yaml:"per_ip" mapstructure:"per_ip"`
+	// Routes override PerClient for specific routes, which then have their
+	// own bucket for every client.
+	Routes []RouteRateLimit `yaml:"routes" mapstructure:"routes"`
+}
+
+// RouteCacheControl overrides the Cache-Control policy of a catalog read.
+type RouteCacheControl struct {
+	// Path is the path template of the route, e.g. /v1/services/{serviceId}.
+	Path string `yaml:"path" mapstructure:"path"`
+	// Policy is the value of the Cache-Control header of the route.
+	Policy string `yaml:"policy" mapstructure:"policy"`
+}
+
+// CacheControl configures the Cache-Control header of the catalog reads, which
+// clients can revalidate with If-None-Match or If-Modified-Since.
+type CacheControl struct {
+	// Default is the policy of the catalog reads without their own.
+	Default string `yaml:"default" mapstructure:"default"`
+	// Routes override Default for specific routes.
+	Routes []RouteCacheControl `yaml:"routes" mapstructure:"routes"`
+}
+
+// Idempotency configures the idempotency keys of the creations. The first
+// response to a creation with an Idempotency-Key header is replayed to the
+// retries with the same key for Window.
+type Idempotency struct {
+	// Window is how long the responses are kept for retries.
+	Window time.Duration `yaml:"window" mapstructure:"window"`
+}
+
+// SoftDelete configures how long deleted services and service versions can be
+// restored before they are purged.
+type SoftDelete struct {
+	// PurgeAfter is how long deleted services and service versions are kept
+	// before they are permanently removed. Zero keeps them forever.
+	PurgeAfter time.Duration `yaml:"purge_after" mapstructure:"purge_after"`
+	// PurgeInterval is how often the purge runs.
+	PurgeInterval time.Duration `yaml:"purge_interval" mapstructure:"purge_interval"`
+}
+
+// FaultRule injects faults in a fraction of the requests of a route. The
+// latency is added first, then the request is dropped, hung or answered with
+// the error status, if set, instead of being handled.
+type FaultRule struct {
+	// Method is the HTTP method of the route.
+	Method string `yaml:"method" mapstructure:"method"`
+	// Path is the path template of the route, e.g. /v1/services/{serviceId}.
+	Path string `yaml:"path" mapstructure:"path"`
+	// Probability is the fraction of the requests of the route, between 0 and
+	// 1, in which the faults are injected.
+	Probability float64 `yaml:"probability" mapstructure:"probability"`
+	// Latency delays the requests.
+	Latency time.Duration `yaml:"latency" mapstructure:"latency"`
+	// Status answers the requests with an error of this HTTP status.
+	Status int `yaml:"status" mapstructure:"status"`
+	// Hang holds the requests until the client gives up.
+	Hang bool `yaml:"hang" mapstructure:"hang"`
+	// Drop closes the connection without a response.
+	Drop bool `yaml:"drop" mapstructure:"drop"`
+}
+
+// FaultInjection configures the faults injected in requests for chaos testing.
+// It can be changed at runtime through /v1/admin/faults.
+type FaultInjection struct {
+	// Enabled turns fault injection on.
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Seed makes the injected faults reproducible; a random seed is used when
+	// it is zero.
+	Seed int64 `yaml:"seed" mapstructure:"seed"`
+	// Rules are the faults injected per route.
+	Rules []FaultRule `yaml:"rules" mapstructure:"rules"`
+}
+
 // Config is the configuration for the candidate take home exercise (SDET) to run.
 type Config struct {
-	// JWTSecret is the configuration for the secret key used for signing JWT tokens.
+	// JWTSecret is the configuration for the secret key used for signing JWT
+	// tokens when no keys are configured. There is no default: the server does
+	// not start without either keys or a secret.
 	JWTSecret string `yaml:"jwt_secret" mapstructure:"jwt_secret"`
+	// JWTKeys are the keys used to verify JWT tokens. Tokens are signed with
+	// JWTSecret (HS256) when no keys are configured.
+	JWTKeys []SigningKey `yaml:"jwt_keys" mapstructure:"jwt_keys"`
+	// JWTSigningKey is the kid of the key in JWTKeys used to sign new tokens.
+	JWTSigningKey string `yaml:"jwt_signing_key" mapstructure:"jwt_signing_key"`
+	// JWTIssuer is the issuer (iss) of the tokens issued by the server, and the
+	// only issuer accepted when verifying tokens.
+	JWTIssuer string `yaml:"jwt_issuer" mapstructure:"jwt_issuer"`
+	// JWTAudience is the audience (aud) of the tokens issued by the server, and
+	// the audience required when verifying tokens.
+	JWTAudience string `yaml:"jwt_audience" mapstructure:"jwt_audience"`
+	// JWTClockSkew is the clock skew allowed when verifying the exp, nbf and
+	// iat claims of tokens.
+	JWTClockSkew time.Duration `yaml:"jwt_clock_skew" mapstructure:"jwt_clock_skew"`
+	// TrustedIssuers are the external identity providers whose tokens are
+	// accepted along with the tokens issued by the server.
+	TrustedIssuers []TrustedIssuer `yaml:"trusted_issuers" mapstructure:"trusted_issuers"`
 	// JWTTokenTimeout is the timeout for token to expire.
 	JWTTokenTimeout time.Duration `yaml:"jwt_token_timeout" mapstructure:"jwt_token_timeout"`
-	// Username is the authorized user.
+	// RefreshTokenTimeout is the timeout for refresh tokens to expire.
+	RefreshTokenTimeout time.Duration `yaml:"refresh_token_timeout" mapstructure:"refresh_token_timeout"`
+	// Username is the username of the admin account created at startup.
 	Username string `yaml:"username" mapstructure:"username"`
-	// Password is the password for the authorized user.
+	// Password is the password of the admin account created at startup.
 	Password string `yaml:"password" mapstructure:"password"`
+	// LoginProtection configures the protection of /v1/token against
+	// brute-force attacks.
+	LoginProtection LoginProtection `yaml:"login_protection" mapstructure:"login_protection"`
+	// RateLimiting configures the rate limits of the requests.
+	RateLimiting RateLimiting `yaml:"rate_limit" mapstructure:"rate_limit"`
+	// FaultInjection configures the faults injected in requests.
+	FaultInjection FaultInjection `yaml:"fault_injection" mapstructure:"fault_injection"`
+	// CacheControl configures the caching of the catalog reads.
+	CacheControl CacheControl `yaml:"cache_control" mapstructure:"cache_control"`
+	// Idempotency configures the idempotency keys of the creations.
+	Idempotency Idempotency `yaml:"idempotency" mapstructure:"idempotency"`
+	// SoftDelete configures the purge of deleted services and versions.
+	SoftDelete SoftDelete `yaml:"soft_delete" mapstructure:"soft_delete"`
 	// RequestTimeout is the timeout for request operations.
 	RequestTimeout time.Duration `yaml:"request_timeout" mapstructure:"request_timeout"`
+	// Roles maps each role (viewer, editor and admin) to the permissions it
+	// grants: catalog:read, catalog:write, users:manage, faults:manage and
+	// audit:read.
+	Roles map[string][]string `yaml:"roles" mapstructure:"roles"`
+	// ResponseValidation is the mode for validating responses against the
+	// OpenAPI specification; one of disabled, log or enforce.
+	ResponseValidation string `yaml:"response_validation" mapstructure:"response_validation"`
 }
 
 // NewConfig creates a new configuration comprised of the configuration file,
 // environment variables, and defaults.
 func NewConfig() (*Config, error) {
 	// Set default configuration vaules
-	viper.SetDefault("jwt_secret", defaultJWTSecret)
+	viper.SetDefault("jwt_issuer", defaultJWTIssuer)
+	viper.SetDefault("jwt_audience", defaultJWTAudience)
+	viper.SetDefault("jwt_clock_skew", defaultJWTClockSkew)
+	viper.SetDefault("refresh_token_timeout", defaultRefreshTimeout)
 	viper.SetDefault("username", defaultUsername)
 	viper.SetDefault("password", defaultPassword)
+	viper.SetDefault("login_protection.max_failures_per_username", defaultMaxFailuresPerUsername)
+	viper.SetDefault("login_protection.max_failures_per_ip", defaultMaxFailuresPerIP)
+	viper.SetDefault("login_protection.base_lockout", defaultBaseLockout)
+	viper.SetDefault("login_protection.max_lockout", defaultMaxLockout)
+	viper.SetDefault("login_protection.failure_window", defaultFailureWindow)
+	viper.SetDefault("login_protection.trust_forwarded_for", false)
+	viper.SetDefault("rate_limit.per_client.requests_per_second", defaultClientRequestsPerSecond)
+	viper.SetDefault("rate_limit.per_client.burst", defaultClientBurst)
+	viper.SetDefault("rate_limit.per_ip.requests_per_second", defaultIPRequestsPerSecond)
+	viper.SetDefault("rate_limit.per_ip.burst", defaultIPBurst)
+	viper.SetDefault("fault_injection.enabled", false)
+	viper.SetDefault("cache_control.default", defaultCacheControl)
+	viper.SetDefault("idempotency.window", defaultIdempotencyWindow)
+	viper.SetDefault("soft_delete.purge_after", defaultPurgeAfter)
+	viper.SetDefault("soft_delete.purge_interval", defaultPurgeInterval)
 	viper.SetDefault("request_timeout", defaultRequestTimeout)
+	viper.SetDefault("roles", defaultRoles)
+	viper.SetDefault("response_validation", ResponseValidationDisabled)
 
 	// Configuration setup for viper
 	viper.SetConfigName("config")
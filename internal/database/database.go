@@ -17,17 +17,26 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 )
 
 // NewDatabase creates a new SQLite database instance with pre-populated data.
 func NewDatabase() (*sql.DB, error) {
-	// Connect to the SQLite database
-	db, err := sql.Open("sqlite3", "./candidate-take-home-exercise-sdet.db")
+	// Connect to the SQLite database, enforcing the foreign keys on every connection
+	db, err := sql.Open("sqlite3", "./candidate-take-home-exercise-sdet.db?_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("unable to create andidate-take-home-exercise-sdet.db: %w", err)
 	}
 
 	// Drop existing tables to ensure a clean start
+	_, err = db.Exec(`DROP TABLE IF EXISTS service_versions_fts`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP service_versions_fts table: %w", fts5Error(err))
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS services_fts`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP services_fts table: %w", fts5Error(err))
+	}
 	_, err = db.Exec(`DROP TABLE IF EXISTS service_versions`)
 	if err != nil {
 		return nil, fmt.Errorf("unable to DROP service_versions table: %w", err)
@@ -36,15 +45,50 @@ func NewDatabase() (*sql.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to DROP services table: %w", err)
 	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS login_attempts`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP login_attempts table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS api_keys`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP api_keys table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS revoked_tokens`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP revoked_tokens table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS refresh_tokens`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP refresh_tokens table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS idempotency_keys`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP idempotency_keys table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS audit_events`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP audit_events table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS service_history`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP service_history table: %w", err)
+	}
+	_, err = db.Exec(`DROP TABLE IF EXISTS users`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to DROP users table: %w", err)
+	}
 
 	// Create the tables
 	_, err = db.Exec(`
         CREATE TABLE services (
-            id TEXT PRIMARY KEY,
+            seq INTEGER PRIMARY KEY AUTOINCREMENT,
+            id TEXT NOT NULL UNIQUE,
             name TEXT CHECK(length(name) <= 64),
             description TEXT CHECK(length(description) <= 255),
+            revision INTEGER NOT NULL DEFAULT 1,
             created_at DATETIME NOT NULL,
-            updated_at DATETIME NOT NULL
+            updated_at DATETIME NOT NULL,
+            deleted_at DATETIME
         )
     `)
 	if err != nil {
@@ -52,11 +96,15 @@ func NewDatabase() (*sql.DB, error) {
 	}
 	_, err = db.Exec(`
         CREATE TABLE service_versions (
-            id TEXT PRIMARY KEY,
+            seq INTEGER PRIMARY KEY AUTOINCREMENT,
+            id TEXT NOT NULL UNIQUE,
             service_id TEXT NOT NULL,
             version TEXT NOT NULL CHECK(length(version) <= 16),
+            revision INTEGER NOT NULL DEFAULT 1,
             created_at DATETIME NOT NULL,
             updated_at DATETIME NOT NULL,
+            deleted_at DATETIME,
+            deleted_with_service BOOLEAN NOT NULL DEFAULT 0,
             FOREIGN KEY (service_id) REFERENCES services(id)
         )
     `)
@@ -64,6 +112,240 @@ func NewDatabase() (*sql.DB, error) {
 		return nil, fmt.Errorf("unable to CREATE service_versions table: %w", err)
 	}
 
+	_, err = db.Exec(`
+        CREATE TABLE users (
+            id TEXT PRIMARY KEY,
+            username TEXT NOT NULL UNIQUE CHECK(length(username) <= 64),
+            password_hash TEXT NOT NULL,
+            role TEXT NOT NULL DEFAULT 'viewer' CHECK(role IN ('viewer', 'editor', 'admin')),
+            disabled BOOLEAN NOT NULL DEFAULT FALSE,
+            created_at DATETIME NOT NULL,
+            updated_at DATETIME NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE users table: %w", err)
+	}
+
+	// Refresh tokens are stored hashed. Every refresh token is used once and
+	// replaced by a new one in the same family when the access token is renewed.
+	_, err = db.Exec(`
+        CREATE TABLE refresh_tokens (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL,
+            username TEXT NOT NULL,
+            token_hash TEXT NOT NULL UNIQUE,
+            expires_at DATETIME NOT NULL,
+            created_at DATETIME NOT NULL,
+            used_at DATETIME,
+            revoked_at DATETIME
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE refresh_tokens table: %w", err)
+	}
+	_, err = db.Exec(`
+        CREATE TABLE revoked_tokens (
+            jti TEXT PRIMARY KEY,
+            expires_at DATETIME NOT NULL,
+            revoked_at DATETIME NOT NULL
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE revoked_tokens table: %w", err)
+	}
+
+	// API keys are stored hashed, along with a prefix that identifies them in
+	// listings and logs without revealing them.
+	_, err = db.Exec(`
+        CREATE TABLE api_keys (
+            id TEXT PRIMARY KEY,
+            name TEXT NOT NULL CHECK (length(name) <= 64),
+            prefix TEXT NOT NULL,
+            key_hash TEXT NOT NULL UNIQUE,
+            username TEXT NOT NULL,
+            created_at DATETIME NOT NULL,
+            last_used_at DATETIME,
+            revoked_at DATETIME
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE api_keys table: %w", err)
+	}
+
+	// The login audit trail records every attempt to obtain a token.
+	_, err = db.Exec(`
+        CREATE TABLE login_attempts (
+            id TEXT PRIMARY KEY,
+            username TEXT NOT NULL,
+            ip TEXT NOT NULL,
+            outcome TEXT NOT NULL,
+            request_id TEXT NOT NULL,
+            attempted_at DATETIME NOT NULL
+        );
+        CREATE INDEX login_attempts_username ON login_attempts (username, attempted_at)
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE login_attempts table: %w", err)
+	}
+
+	// Idempotency keys hold the first response to a creation, replayed when a
+	// client retries it with the same key. The status is NULL while the first
+	// request is in progress.
+	_, err = db.Exec(`
+        CREATE TABLE idempotency_keys (
+            client TEXT NOT NULL,
+            key TEXT NOT NULL,
+            request_hash TEXT NOT NULL,
+            status INTEGER,
+            header TEXT,
+            body BLOB,
+            created_at DATETIME NOT NULL,
+            PRIMARY KEY (client, key)
+        )
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE idempotency_keys table: %w", err)
+	}
+
+	// The audit log records every change to the catalog and to the credentials,
+	// with the state of the changed resource before and after the change.
+	_, err = db.Exec(`
+        CREATE TABLE audit_events (
+            id TEXT PRIMARY KEY,
+            actor TEXT NOT NULL,
+            action TEXT NOT NULL,
+            resource_type TEXT NOT NULL,
+            resource_id TEXT NOT NULL,
+            before TEXT,
+            after TEXT,
+            request_id TEXT NOT NULL,
+            created_at DATETIME NOT NULL
+        );
+        CREATE INDEX audit_events_created_at ON audit_events (created_at);
+        CREATE INDEX audit_events_resource ON audit_events (resource_type, resource_id, created_at)
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE audit_events table: %w", err)
+	}
+
+	// Every revision of the services and of their versions is copied to the
+	// history by triggers, valid from the time of the change until the next
+	// revision, or until the service or version is purged. The history outlives
+	// the purge. Resources are identified by their rowid, which is never reused,
+	// so the history of a purged resource is never continued by a later one.
+	_, err = db.Exec(`
+        CREATE TABLE service_history (
+            seq INTEGER PRIMARY KEY AUTOINCREMENT,
+            service_id TEXT NOT NULL,
+            resource_type TEXT NOT NULL,
+            resource_rowid INTEGER NOT NULL,
+            resource_id TEXT NOT NULL,
+            revision INTEGER NOT NULL,
+            name TEXT,
+            description TEXT,
+            version TEXT,
+            created_at DATETIME NOT NULL,
+            updated_at DATETIME NOT NULL,
+            deleted_at DATETIME,
+            valid_from DATETIME NOT NULL,
+            valid_to DATETIME
+        );
+        CREATE INDEX service_history_service ON service_history (service_id, seq);
+        CREATE INDEX service_history_validity ON service_history (resource_type, valid_from, valid_to);
+        CREATE TRIGGER services_history_insert AFTER INSERT ON services BEGIN
+            INSERT INTO service_history (service_id, resource_type, resource_rowid, resource_id, revision, name,
+                description, created_at, updated_at, deleted_at, valid_from)
+            VALUES (new.id, 'service', new.rowid, new.id, new.revision, new.name, new.description, new.created_at,
+                new.updated_at, new.deleted_at, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+        END;
+        CREATE TRIGGER services_history_update AFTER UPDATE ON services WHEN new.revision <> old.revision BEGIN
+            UPDATE service_history SET valid_to = strftime('%Y-%m-%d %H:%M:%f', 'now')
+            WHERE resource_type = 'service' AND resource_rowid = new.rowid AND valid_to IS NULL;
+            INSERT INTO service_history (service_id, resource_type, resource_rowid, resource_id, revision, name,
+                description, created_at, updated_at, deleted_at, valid_from)
+            VALUES (new.id, 'service', new.rowid, new.id, new.revision, new.name, new.description, new.created_at,
+                new.updated_at, new.deleted_at, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+        END;
+        CREATE TRIGGER services_history_delete AFTER DELETE ON services BEGIN
+            UPDATE service_history SET valid_to = strftime('%Y-%m-%d %H:%M:%f', 'now')
+            WHERE resource_type = 'service' AND resource_rowid = old.rowid AND valid_to IS NULL;
+        END;
+        CREATE TRIGGER service_versions_history_insert AFTER INSERT ON service_versions BEGIN
+            INSERT INTO service_history (service_id, resource_type, resource_rowid, resource_id, revision, version,
+                created_at, updated_at, deleted_at, valid_from)
+            VALUES (new.service_id, 'service_version', new.rowid, new.id, new.revision, new.version, new.created_at,
+                new.updated_at, new.deleted_at, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+        END;
+        CREATE TRIGGER service_versions_history_update AFTER UPDATE ON service_versions
+        WHEN new.revision <> old.revision BEGIN
+            UPDATE service_history SET valid_to = strftime('%Y-%m-%d %H:%M:%f', 'now')
+            WHERE resource_type = 'service_version' AND resource_rowid = new.rowid AND valid_to IS NULL;
+            INSERT INTO service_history (service_id, resource_type, resource_rowid, resource_id, revision, version,
+                created_at, updated_at, deleted_at, valid_from)
+            VALUES (new.service_id, 'service_version', new.rowid, new.id, new.revision, new.version, new.created_at,
+                new.updated_at, new.deleted_at, strftime('%Y-%m-%d %H:%M:%f', 'now'));
+        END;
+        CREATE TRIGGER service_versions_history_delete AFTER DELETE ON service_versions BEGIN
+            UPDATE service_history SET valid_to = strftime('%Y-%m-%d %H:%M:%f', 'now')
+            WHERE resource_type = 'service_version' AND resource_rowid = old.rowid AND valid_to IS NULL;
+        END;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE service_history table: %w", err)
+	}
+
+	// Create the full-text search indexes. They are external content tables
+	// backed by the services and service_versions tables and kept in sync by
+	// triggers, so they only store the search index itself.
+	_, err = db.Exec(`
+        CREATE VIRTUAL TABLE services_fts USING fts5(
+            name,
+            description,
+            content='services',
+            content_rowid='rowid',
+            tokenize='unicode61 remove_diacritics 2'
+        );
+        CREATE TRIGGER services_fts_insert AFTER INSERT ON services BEGIN
+            INSERT INTO services_fts(rowid, name, description) VALUES (new.rowid, new.name, new.description);
+        END;
+        CREATE TRIGGER services_fts_delete AFTER DELETE ON services BEGIN
+            INSERT INTO services_fts(services_fts, rowid, name, description)
+            VALUES ('delete', old.rowid, old.name, old.description);
+        END;
+        CREATE TRIGGER services_fts_update AFTER UPDATE ON services BEGIN
+            INSERT INTO services_fts(services_fts, rowid, name, description)
+            VALUES ('delete', old.rowid, old.name, old.description);
+            INSERT INTO services_fts(rowid, name, description) VALUES (new.rowid, new.name, new.description);
+        END;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE services_fts table: %w", fts5Error(err))
+	}
+	_, err = db.Exec(`
+        CREATE VIRTUAL TABLE service_versions_fts USING fts5(
+            version,
+            content='service_versions',
+            content_rowid='rowid',
+            tokenize='unicode61 remove_diacritics 2'
+        );
+        CREATE TRIGGER service_versions_fts_insert AFTER INSERT ON service_versions BEGIN
+            INSERT INTO service_versions_fts(rowid, version) VALUES (new.rowid, new.version);
+        END;
+        CREATE TRIGGER service_versions_fts_delete AFTER DELETE ON service_versions BEGIN
+            INSERT INTO service_versions_fts(service_versions_fts, rowid, version)
+            VALUES ('delete', old.rowid, old.version);
+        END;
+        CREATE TRIGGER service_versions_fts_update AFTER UPDATE ON service_versions BEGIN
+            INSERT INTO service_versions_fts(service_versions_fts, rowid, version)
+            VALUES ('delete', old.rowid, old.version);
+            INSERT INTO service_versions_fts(rowid, version) VALUES (new.rowid, new.version);
+        END;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("unable to CREATE service_versions_fts table: %w", fts5Error(err))
+	}
+
 	// Insert initial data to populate the services table
 	//nolint:lll
 	initialServices := []struct {
@@ -172,3 +454,12 @@ func NewDatabase() (*sql.DB, error) {
 
 	return db, nil
 }
+
+// fts5Error explains the errors of a SQLite built without the fts5 module,
+// which the search requires, and returns the other errors unchanged.
+func fts5Error(err error) error {
+	if strings.Contains(err.Error(), "no such module: fts5") {
+		return fmt.Errorf("SQLite was built without full-text search, build with -tags sqlite_fts5: %w", err)
+	}
+	return err
+}
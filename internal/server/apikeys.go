@@ -0,0 +1,304 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	// apiKeyHeader is the header carrying the API key of a request.
+	apiKeyHeader = "X-API-Key"
+	// apiKeyPrefix starts every API key, so that leaked keys are easy to spot.
+	apiKeyPrefix = "kc_"
+	// apiKeyPrefixLength is the length of the prefix stored in clear to
+	// identify a key.
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+)
+
+// APIKey represents a key that authenticates requests on behalf of a user,
+// with the role of that user.
+type APIKey struct {
+	// Unique identifier for the API key.
+	ID string `json:"id"`
+	// Name describing what the key is used for.
+	Name string `json:"name"`
+	// Prefix is the beginning of the key, to recognize it without revealing it.
+	Prefix string `json:"prefix"`
+	// Username of the user owning the key.
+	Username string `json:"username"`
+	// Timestamp when the key was created.
+	CreatedAt time.Time `json:"created_at"`
+	// Timestamp when the key last authenticated a request, if ever.
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Timestamp when the key was revoked, if it was.
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// NewAPIKey represents the payload used to create an API key.
+type NewAPIKey struct {
+	// Name describing what the key is used for.
+	Name string `json:"name"`
+}
+
+// apiKeyColumns are the columns scanned by scanAPIKey.
+const apiKeyColumns = "id, name, prefix, username, created_at, last_used_at, revoked_at"
+
+// scanAPIKey scans a row selected with apiKeyColumns.
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (APIKey, error) {
+	var key APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Username, &key.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// authenticateAPIKey authenticates a request using the API key in the
+// X-API-Key header and records when the key was last used.
+func (h *Handler) authenticateAPIKey(r *http.Request, apiKey string) (*Claims, error) {
+	var id, username, role string
+	err := h.db.QueryRow(`
+		SELECT k.id, k.username, u.role FROM api_keys k JOIN users u ON u.username = k.username
+		WHERE k.key_hash = ? AND k.revoked_at IS NULL AND NOT u.disabled`,
+		hashToken(apiKey)).Scan(&id, &username, &role)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid API key", zap.String("prefix", keyPrefix(apiKey)), zap.Error(err))
+		return nil, errors.New("invalid API key")
+	}
+
+	_, err = h.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now().UTC(), id)
+	if err != nil {
+		h.requestLogger(r).Warn("failed to record API key usage", zap.String("api_key", id), zap.Error(err))
+	}
+	return &Claims{APIKeyID: id, Username: username, Roles: []string{role}}, nil
+}
+
+// keyPrefix returns the part of an API key that is safe to store and log.
+func keyPrefix(apiKey string) string {
+	if len(apiKey) < apiKeyPrefixLength {
+		return apiKey
+	}
+	return apiKey[:apiKeyPrefixLength]
+}
+
+// CreateAPIKeyHandler creates an API key for the caller. The key itself is
+// only returned in this response.
+func (h *Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		WriteError(w, r, errUnauthorized)
+		return
+	}
+
+	// API keys act with the role of a user of the server, which users of a
+	// trusted issuer are not.
+	if claims.Issuer != "" {
+		h.requestLogger(r).Warn("API key requested by an external user", zap.String("issuer", claims.Issuer))
+		WriteError(w, r, errForbidden)
+		return
+	}
+
+	// Decode the JSON payload from the request body.
+	var newKey NewAPIKey
+	err := json.NewDecoder(r.Body).Decode(&newKey)
+	if err != nil {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+	if strings.TrimSpace(newKey.Name) == "" {
+		WriteError(w, r, newValidationError(FieldError{Field: "name", Message: "must not be blank"}))
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		h.requestLogger(r).Error("failed to generate API key", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	apiKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	id := uuid.NewString()
+	_, err = tx.ExecContext(r.Context(), `
+		INSERT INTO api_keys (id, name, prefix, key_hash, username, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		id, newKey.Name, keyPrefix(apiKey), hashToken(apiKey), claims.Username, time.Now().UTC())
+	if err != nil {
+		h.requestLogger(r).Error("failed to insert API key", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	key, err := scanAPIKey(tx.QueryRowContext(r.Context(), "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", id))
+	if err != nil {
+		h.requestLogger(r).Error("failed to fetch inserted API key", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if err := h.commitWithAudit(r, tx, auditEntry{auditCreate, auditAPIKey, key.ID, nil, key}); err != nil {
+		h.requestLogger(r).Error("failed to commit API key creation", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("API key created", zap.String("api_key", key.ID), zap.String("prefix", key.Prefix))
+
+	// Set the response status to 201 Created and encode the new key as JSON.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": key, "key": apiKey})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// ListAPIKeysHandler lists the API keys of the caller one page at a time,
+// including revoked ones. Callers allowed to manage users see every key.
+func (h *Handler) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		WriteError(w, r, errUnauthorized)
+		return
+	}
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	// Only the keys of the caller are listed, unless it can manage users.
+	all := h.permissions.allows(claims.Roles, PermissionUsersManage)
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE (? OR username = ?)", all, claims.Username).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count API keys", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM api_keys WHERE (? OR username = ?)
+		ORDER BY created_at, id LIMIT ? OFFSET ?`, apiKeyColumns)
+	rows, err := h.db.Query(query, all, claims.Username, limit, offset(page, limit))
+	if err != nil {
+		h.requestLogger(r).Error("failed to query API keys", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan API key", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      keys,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// RevokeAPIKeyHandler revokes an API key of the caller. Callers allowed to
+// manage users can revoke any key. Revoking a revoked key has no effect.
+func (h *Handler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		WriteError(w, r, errUnauthorized)
+		return
+	}
+	// Get the API key ID from the URL path variables.
+	keyID := mux.Vars(r)["keyId"]
+
+	// Keys of other users are reported as not found, unless the caller can
+	// manage users.
+	all := h.permissions.allows(claims.Roles, PermissionUsersManage)
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := scanAPIKey(tx.QueryRowContext(r.Context(),
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ? AND (? OR username = ?)", keyID, all, claims.Username))
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, r, errAPIKeyNotFound)
+		return
+	} else if err != nil {
+		h.requestLogger(r).Error("failed to fetch API key", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if before.RevokedAt != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	_, err = tx.ExecContext(r.Context(), "UPDATE api_keys SET revoked_at = ? WHERE id = ?", time.Now().UTC(), keyID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to revoke API key", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	after, err := scanAPIKey(tx.QueryRowContext(r.Context(), "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ?", keyID))
+	if err == nil {
+		err = h.commitWithAudit(r, tx, auditEntry{auditRevoke, auditAPIKey, keyID, before, after})
+	}
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit API key revocation", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("API key revoked", zap.String("api_key", keyID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,262 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Actions recorded in the audit log.
+const (
+	auditCreate  = "create"
+	auditUpdate  = "update"
+	auditDelete  = "delete"
+	auditRestore = "restore"
+	auditRevoke  = "revoke"
+	auditRevert  = "revert"
+	auditPurge   = "purge"
+)
+
+// Types of the resources recorded in the audit log.
+const (
+	auditService        = "service"
+	auditServiceVersion = "service_version"
+	auditUser           = "user"
+	auditAPIKey         = "api_key"
+	auditAccessToken    = "access_token"
+	auditRefreshTokens  = "refresh_token_family"
+)
+
+// systemActor is the actor of the changes made by the server itself: purges,
+// and revocations of refresh tokens presented again. Local users cannot be
+// named after it.
+const systemActor = "system"
+
+// AuditEvent records a change to the catalog or to the credentials.
+type AuditEvent struct {
+	// Unique identifier for the event.
+	ID string `json:"id"`
+	// Actor is the username of the user who made the change, or system for the
+	// changes made by the server itself.
+	Actor string `json:"actor"`
+	// Action is the change: create, update, delete, restore, revoke, revert or
+	// purge.
+	Action string `json:"action"`
+	// ResourceType is the type of the changed resource: service,
+	// service_version, user, api_key, access_token or refresh_token_family.
+	ResourceType string `json:"resource_type"`
+	// ResourceID identifies the changed resource.
+	ResourceID string `json:"resource_id"`
+	// Before is the state of the resource before the change, null for creations.
+	Before json.RawMessage `json:"before"`
+	// After is the state of the resource after the change.
+	After json.RawMessage `json:"after"`
+	// RequestID identifies the request of the change, or the purge run.
+	RequestID string `json:"request_id"`
+	// Timestamp of the change.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// auditEventColumns are the columns of the audit log.
+const auditEventColumns = "id, actor, action, resource_type, resource_id, before, after, request_id, created_at"
+
+// auditFilters are the query parameters filtering the audit log, named after
+// the columns they match.
+var auditFilters = []string{"actor", "action", "resource_type", "resource_id", "request_id"}
+
+// auditEntry is a change to record in the audit log.
+type auditEntry struct {
+	action       string
+	resourceType string
+	resourceID   string
+	before       interface{}
+	after        interface{}
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx, so that reads can take part
+// in the transaction of a change.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// commitWithAudit records the changes of a request in the audit log and
+// commits them, so that changes are never made without being recorded. The
+// changes of unauthenticated requests are made by the system.
+func (h *Handler) commitWithAudit(r *http.Request, tx *sql.Tx, entries ...auditEntry) error {
+	actor := systemActor
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		actor = claims.Username
+	}
+	if err := recordAudit(r.Context(), tx, actor, RequestIDFromContext(r.Context()), entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordAudit records changes in the audit log, in the transaction that made
+// them.
+func recordAudit(ctx context.Context, tx *sql.Tx, actor string, requestID string, entries []auditEntry) error {
+	for _, entry := range entries {
+		before, err := auditState(entry.before)
+		if err != nil {
+			return err
+		}
+		after, err := auditState(entry.after)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO audit_events (`+auditEventColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.NewString(), actor, entry.action, entry.resourceType, entry.resourceID, before, after,
+			requestID, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("unable to record audit event: %w", err)
+		}
+	}
+	return nil
+}
+
+// auditState encodes the state of a resource, or NULL if there is none.
+func auditState(state interface{}) (sql.NullString, error) {
+	if state == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("unable to encode audit state: %w", err)
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// loadService fetches a service, deleted or not, for the audit log. It returns
+// nil if the service does not exist.
+func loadService(ctx context.Context, q dbtx, id string) (*Service, error) {
+	var s Service
+	err := q.QueryRowContext(ctx, `SELECT id, name, description, created_at, updated_at, deleted_at, revision
+		FROM services WHERE id = ?`, id).Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt,
+		&s.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to query service %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// loadServiceVersion fetches a version of a service, deleted or not, for the
+// audit log. It returns nil if the version does not exist.
+func loadServiceVersion(ctx context.Context, q dbtx, serviceID string, id string) (*ServiceVersion, error) {
+	var v ServiceVersion
+	err := q.QueryRowContext(ctx, `SELECT id, service_id, version, created_at, updated_at, deleted_at, revision
+		FROM service_versions WHERE id = ? AND service_id = ?`, id, serviceID).Scan(&v.ID, &v.ServiceID, &v.Version,
+		&v.CreatedAt, &v.UpdatedAt, &v.DeletedAt, &v.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to query service version %s: %w", id, err)
+	}
+	return &v, nil
+}
+
+// ListAuditEventsHandler lists the audit log one page at a time, most recent
+// events first, optionally filtered by actor, action, resource and request, or
+// by time range.
+func (h *Handler) ListAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+	for _, filter := range auditFilters {
+		if value := r.URL.Query().Get(filter); value != "" {
+			conditions = append(conditions, filter+" = ?")
+			args = append(args, value)
+		}
+	}
+	for param, condition := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+		if value := r.URL.Query().Get(param); value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				WriteError(w, r, newValidationError(FieldError{Field: param, Message: "must be a date-time"}))
+				return
+			}
+			conditions = append(conditions, condition)
+			args = append(args, at.UTC())
+		}
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM audit_events WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count audit events", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM audit_events WHERE %s
+		ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`, auditEventColumns, where)
+	rows, err := h.db.Query(query, append(args, limit, offset(page, limit))...)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query audit events", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var e AuditEvent
+		var before, after sql.NullString
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.ResourceType, &e.ResourceID, &before, &after, &e.RequestID,
+			&e.CreatedAt)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan audit event", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		events = append(events, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      events,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Claims identifies the authenticated caller of a request.
+type Claims struct {
+	// TokenID is the unique identifier (jti) of the token used to authenticate the request.
+	TokenID string
+	// SessionID is the refresh token family (sid) of the login the token was
+	// issued for, if any.
+	SessionID string
+	// APIKeyID is the ID of the API key used to authenticate the request, if any.
+	APIKeyID string
+	// Issuer is the trusted issuer of the token used to authenticate the
+	// request, empty for the tokens issued by the server.
+	Issuer string
+	// Username of the authenticated user. The users of trusted issuers are
+	// named issuer|username.
+	Username string
+	// ExpiresAt is when the token used to authenticate the request expires.
+	ExpiresAt time.Time
+	// Roles granted to the user.
+	Roles []string
+}
+
+// tokenClaims are the claims carried by the JWT tokens issued by the server.
+type tokenClaims struct {
+	Username  string   `json:"username"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AuthenticateToken authenticates a request using either an API key in the
+// X-API-Key header or a bearer token in the Authorization header and returns
+// the verified claims of the caller. Bearer tokens are issued either by the
+// server or by one of the trusted issuers.
+func (h *Handler) AuthenticateToken(r *http.Request) (*Claims, error) {
+	if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+		return h.authenticateAPIKey(r, apiKey)
+	}
+
+	// Parse auth and get the token
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		h.requestLogger(r).Warn("missing Authorization header")
+		return nil, errors.New("missing authorization header")
+	}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		h.requestLogger(r).Warn("invalid Authorization header format")
+		return nil, errors.New("invalid authorization header format")
+	}
+	tokenStr := parts[1]
+	if issuer := h.trustedIssuerOf(tokenStr); issuer != nil {
+		return h.authenticateExternalToken(r, issuer, tokenStr)
+	}
+
+	// The signature is verified while parsing, the claims right after, with
+	// the allowed clock skew.
+	var claims tokenClaims
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenStr, &claims, h.keyFunc)
+	if err != nil || !token.Valid {
+		h.requestLogger(r).Warn("invalid token", zap.Error(err))
+		return nil, errInvalidToken
+	}
+	apiErr := h.validateClaims(&claims.RegisteredClaims, h.jwtIssuer, h.jwtAudience, true)
+	if apiErr == nil && claims.Subject != claims.Username {
+		apiErr = errInvalidSubject
+	}
+	if apiErr != nil {
+		h.requestLogger(r).Warn("token claims rejected", zap.String("code", apiErr.Code),
+			zap.String("username", claims.Username))
+		return nil, apiErr
+	}
+
+	// Tokens that were revoked before they expired are rejected.
+	revoked, err := h.isRevoked(claims.ID)
+	if err != nil || revoked {
+		h.requestLogger(r).Warn("revoked token", zap.String("jti", claims.ID), zap.Error(err))
+		return nil, errors.New("revoked token")
+	}
+
+	// Tokens of users that have since been disabled or removed are rejected,
+	// and the others act with the current role of the user rather than the
+	// one in the token, so that demotions take effect immediately.
+	var role string
+	var disabled bool
+	err = h.db.QueryRow("SELECT role, disabled FROM users WHERE username = ?", claims.Username).Scan(&role,
+		&disabled)
+	if err != nil || disabled {
+		h.requestLogger(r).Warn("token of an unknown or disabled user", zap.String("username", claims.Username),
+			zap.Error(err))
+		return nil, errors.New("unknown or disabled user")
+	}
+
+	verified := &Claims{
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+		Username:  claims.Username,
+		Roles:     []string{role},
+	}
+	if claims.ExpiresAt != nil {
+		verified.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return verified, nil
+}
+
+// validateClaims checks the registered claims of a token against the expected
+// issuer and audience, returning an error identifying the first claim that is
+// missing or rejected. The nbf and iat claims, which some identity providers
+// omit, are only checked when present unless requireTimes is set.
+func (h *Handler) validateClaims(claims *jwt.RegisteredClaims, issuer string, audience string,
+	requireTimes bool) *APIError {
+	now := time.Now()
+	switch {
+	case claims.ExpiresAt == nil, requireTimes && (claims.NotBefore == nil || claims.IssuedAt == nil):
+		return errInvalidToken
+	case !claims.VerifyExpiresAt(now.Add(-h.jwtClockSkew), true):
+		return errTokenExpired
+	case !claims.VerifyNotBefore(now.Add(h.jwtClockSkew), requireTimes),
+		!claims.VerifyIssuedAt(now.Add(h.jwtClockSkew), requireTimes):
+		return errTokenNotYetValid
+	case !claims.VerifyIssuer(issuer, true):
+		return errInvalidIssuer
+	case !claims.VerifyAudience(audience, true):
+		return errInvalidAudience
+	case claims.Subject == "":
+		return errInvalidSubject
+	}
+	return nil
+}
+
+// keyFunc returns the key used to verify the signature of the tokens issued by
+// the server. The shared secret is only trusted when no signing keys are
+// configured.
+func (h *Handler) keyFunc(token *jwt.Token) (interface{}, error) {
+	if h.keys != nil {
+		return h.keys.keyFunc(token)
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+	return []byte(h.jwtSecret), nil
+}
+
+// AuthMiddleware authenticates every request before it reaches its handler,
+// responding with a 401 when the API key or bearer token is missing or
+// invalid, with a code identifying why a token was rejected. The verified
+// claims are stored in the request context. Routes whose path template is
+// listed in publicRoutes are served without authentication.
+func (h *Handler) AuthMiddleware(publicRoutes ...string) func(http.Handler) http.Handler {
+	public := make(map[string]bool, len(publicRoutes))
+	for _, route := range publicRoutes {
+		public[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil && public[template] {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			claims, err := h.AuthenticateToken(r)
+			if err != nil {
+				// Rejected tokens are reported with the reason they were
+				// rejected, other failures as unauthorized.
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					apiErr = errUnauthorized
+				}
+				WriteError(w, r, apiErr)
+				return
+			}
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClaimsFromContext returns the claims of the authenticated caller stored in
+// ctx, if any.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
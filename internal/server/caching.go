@@ -0,0 +1,121 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+// cachePolicies are the Cache-Control policies of the catalog reads.
+type cachePolicies struct {
+	defaultPolicy string
+	routes        map[string]string
+}
+
+// newCachePolicies creates the Cache-Control policies from the configuration.
+func newCachePolicies(cfg config.CacheControl) (*cachePolicies, error) {
+	policies := &cachePolicies{defaultPolicy: cfg.Default, routes: map[string]string{}}
+	for _, route := range cfg.Routes {
+		if route.Path == "" || route.Policy == "" {
+			return nil, fmt.Errorf("every cache control route must have a path and a policy")
+		}
+		if _, ok := policies.routes[route.Path]; ok {
+			return nil, fmt.Errorf("duplicate cache control for route %s", route.Path)
+		}
+		policies.routes[route.Path] = route.Policy
+	}
+	return policies, nil
+}
+
+// policy returns the Cache-Control policy of the route of a request.
+func (p *cachePolicies) policy(r *http.Request) string {
+	if policy, ok := p.routes[routeTemplate(r)]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// checkNotModified sets the caching headers of a catalog read, and reports
+// whether the copy of the client is still fresh, in which case 304 Not
+// Modified is written. If-Modified-Since is only checked without
+// If-None-Match, as the ETag is the more precise validator, and is ignored
+// when lastModified is zero.
+func (h *Handler) checkNotModified(w http.ResponseWriter, r *http.Request, tag string,
+	lastModified time.Time) bool {
+	if policy := h.cachePolicies.policy(r); policy != "" {
+		w.Header().Set("Cache-Control", policy)
+	}
+	w.Header().Set("ETag", tag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	var fresh bool
+	if values := r.Header.Values("If-None-Match"); len(values) > 0 {
+		fresh = noneMatch(values, tag)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		// HTTP dates have a precision of a second.
+		fresh = !lastModified.Truncate(time.Second).After(since)
+	}
+	if fresh {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return fresh
+}
+
+// noneMatch reports whether any entity tag of the If-None-Match header
+// matches the tag. The comparison is weak, as for any conditional GET.
+func noneMatch(values []string, tag string) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeCollection writes a page of a collection, or 304 Not Modified if the
+// copy of the client is still fresh. The ETag of the page is a hash of its
+// content, so that any change to the page, deletions included, changes it.
+// Pages have no last modification time, since the times of their items do
+// not change when other items are deleted or inserted on another page.
+func (h *Handler) writeCollection(w http.ResponseWriter, r *http.Request, page interface{}) {
+	body, err := json.Marshal(page)
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	sum := sha256.Sum256(body)
+	if h.checkNotModified(w, r, `W/"`+hex.EncodeToString(sum[:16])+`"`, time.Time{}) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		h.requestLogger(r).Error("unable to write response", zap.Error(err))
+	}
+}
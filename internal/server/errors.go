@@ -0,0 +1,165 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Machine-readable error codes returned in the error envelope.
+const (
+	CodeInvalidRequest         = "invalid_request"
+	CodeValidationFailed       = "validation_failed"
+	CodeInvalidPagination      = "invalid_pagination"
+	CodeInvalidSort            = "invalid_sort"
+	CodeInvalidSearchQuery     = "invalid_search_query"
+	CodeUnauthorized           = "unauthorized"
+	CodeInvalidToken           = "invalid_token"
+	CodeTokenExpired           = "token_expired"
+	CodeTokenNotYetValid       = "token_not_yet_valid"
+	CodeInvalidTokenIssuer     = "invalid_token_issuer"
+	CodeInvalidTokenAudience   = "invalid_token_audience"
+	CodeInvalidTokenSubject    = "invalid_token_subject"
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeInvalidRefreshToken    = "invalid_refresh_token"
+	CodeTooManyLoginAttempts   = "too_many_login_attempts"
+	CodeRateLimited            = "rate_limited"
+	CodeForbidden              = "forbidden"
+	CodeNotFound               = "not_found"
+	CodeServiceNotFound        = "service_not_found"
+	CodeServiceVersionNotFound = "service_version_not_found"
+	CodeServiceHasVersions     = "service_has_versions"
+	CodeRevisionNotFound       = "revision_not_found"
+	CodeUserNotFound           = "user_not_found"
+	CodeUserExists             = "user_already_exists"
+	CodeIdempotencyKeyReused   = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse    = "idempotency_key_in_use"
+	CodeAPIKeyNotFound         = "api_key_not_found"
+	CodeMethodNotAllowed       = "method_not_allowed"
+	CodePreconditionFailed     = "precondition_failed"
+	CodeTimeout                = "timeout"
+	CodeInjectedFault          = "injected_fault"
+	CodeInternal               = "internal_error"
+	CodeUnavailable            = "service_unavailable"
+	CodeContractViolation      = "contract_violation"
+)
+
+var (
+	errInvalidPayload     = NewAPIError(http.StatusBadRequest, CodeInvalidRequest, "Invalid request payload")
+	errInvalidPagination  = NewAPIError(http.StatusBadRequest, CodeInvalidPagination, "Invalid pagination parameters")
+	errInvalidSort        = NewAPIError(http.StatusBadRequest, CodeInvalidSort, "Invalid sort parameters")
+	errInvalidSearchQuery = NewAPIError(http.StatusBadRequest, CodeInvalidSearchQuery, "Invalid search query")
+	errUnauthorized       = NewAPIError(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized")
+	errInvalidToken       = NewAPIError(http.StatusUnauthorized, CodeInvalidToken, "Invalid token")
+	errTokenExpired       = NewAPIError(http.StatusUnauthorized, CodeTokenExpired, "Token has expired")
+	errTokenNotYetValid   = NewAPIError(http.StatusUnauthorized, CodeTokenNotYetValid, "Token is not valid yet")
+	errInvalidIssuer      = NewAPIError(http.StatusUnauthorized, CodeInvalidTokenIssuer, "Token issuer is not trusted")
+	errInvalidAudience    = NewAPIError(http.StatusUnauthorized, CodeInvalidTokenAudience, "Token audience is not accepted")
+	errInvalidSubject     = NewAPIError(http.StatusUnauthorized, CodeInvalidTokenSubject, "Token subject is invalid")
+	errNotFound           = NewAPIError(http.StatusNotFound, CodeNotFound, "Resource not found")
+	errServiceNotFound    = NewAPIError(http.StatusNotFound, CodeServiceNotFound, "Service not found")
+	errVersionNotFound    = NewAPIError(http.StatusNotFound, CodeServiceVersionNotFound, "Service version not found")
+	errServiceHasVersions = NewAPIError(http.StatusConflict, CodeServiceHasVersions, "Service still has versions")
+	errRevisionNotFound   = NewAPIError(http.StatusNotFound, CodeRevisionNotFound, "Service revision not found")
+	errMethodNotAllowed   = NewAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+	errPreconditionFailed = NewAPIError(http.StatusPreconditionFailed, CodePreconditionFailed, "Resource has changed")
+	errTimeout            = NewAPIError(http.StatusGatewayTimeout, CodeTimeout, "Request timed out")
+	errInternal           = NewAPIError(http.StatusInternalServerError, CodeInternal, "Internal server error")
+	errUnavailable        = NewAPIError(http.StatusServiceUnavailable, CodeUnavailable, "Service unavailable")
+	errVersionTooLong     = newValidationError(FieldError{Field: "version", Message: "must be at most 16 characters"})
+	errInvalidCredentials = NewAPIError(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+	errForbidden          = NewAPIError(http.StatusForbidden, CodeForbidden, "Forbidden")
+	errInvalidRefresh     = NewAPIError(http.StatusUnauthorized, CodeInvalidRefreshToken, "Invalid refresh token")
+	errTooManyLogins      = NewAPIError(http.StatusTooManyRequests, CodeTooManyLoginAttempts, "Too many failed logins")
+	errTooManyRequests    = NewAPIError(http.StatusTooManyRequests, CodeRateLimited, "Too many requests")
+	errUserNotFound       = NewAPIError(http.StatusNotFound, CodeUserNotFound, "User not found")
+	errUserExists         = NewAPIError(http.StatusConflict, CodeUserExists, "User already exists")
+	errIdempotencyReuse   = NewAPIError(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Key already used")
+	errIdempotencyBusy    = NewAPIError(http.StatusConflict, CodeIdempotencyKeyInUse, "Request in progress")
+	errAPIKeyNotFound     = NewAPIError(http.StatusNotFound, CodeAPIKeyNotFound, "API key not found")
+)
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	// Field is the name of the offending field or parameter.
+	Field string `json:"field"`
+	// Message explains what is wrong with the field.
+	Message string `json:"message"`
+}
+
+// APIError is the error returned by every endpoint, serialized as
+// {"error": {...}} with an application/json content type.
+type APIError struct {
+	// Status is the HTTP status code of the response.
+	Status int `json:"-"`
+	// Code is a stable, machine-readable error code.
+	Code string `json:"code"`
+	// Message is a human-readable description of the error.
+	Message string `json:"message"`
+	// Details lists field-level violations, if any.
+	Details []FieldError `json:"details,omitempty"`
+	// RequestID identifies the request that caused the error.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// NewAPIError creates an error for the given status, code and message.
+func NewAPIError(status int, code string, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// newValidationError creates a validation error listing the field violations.
+func newValidationError(details ...FieldError) *APIError {
+	err := NewAPIError(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+	err.Details = details
+	return err
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// WriteError writes err as a JSON error envelope. Errors that are not an
+// *APIError are reported as internal server errors so that no internal
+// details leak to the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = errInternal
+	}
+	body := *apiErr
+	body.RequestID = RequestIDFromContext(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(body.Status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": body})
+}
+
+// NotFoundHandler returns the JSON error response for unknown routes.
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errNotFound)
+	})
+}
+
+// MethodNotAllowedHandler returns the JSON error response for known routes
+// requested with an unsupported method.
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, errMethodNotAllowed)
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// etag returns the entity tag of a row revision.
+func etag(revision int64) string {
+	return strconv.Quote(strconv.FormatInt(revision, 10))
+}
+
+// setETag sets the ETag header to the revision of a row.
+func setETag(w http.ResponseWriter, revision int64) {
+	w.Header().Set("ETag", etag(revision))
+}
+
+// precondition is the set of revisions accepted by the If-Match header of a
+// request.
+type precondition struct {
+	// any is set when the header is missing or is "*".
+	any       bool
+	revisions []interface{}
+}
+
+// parseIfMatch parses the If-Match header of a request. Weak and malformed
+// entity tags are ignored, as If-Match only matches strong ones.
+func parseIfMatch(r *http.Request) precondition {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return precondition{any: true}
+	}
+	var p precondition
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return precondition{any: true}
+			}
+			unquoted, err := strconv.Unquote(tag)
+			if err != nil || !strings.HasPrefix(tag, `"`) {
+				continue
+			}
+			if revision, err := strconv.ParseInt(unquoted, 10, 64); err == nil {
+				p.revisions = append(p.revisions, revision)
+			}
+		}
+	}
+	return p
+}
+
+// clause returns the SQL condition on the revision column along with its
+// arguments, to append to the WHERE clause of an update or a delete so that
+// rows changed in the meantime are left untouched. The condition is empty when
+// any revision is accepted.
+func (p precondition) clause() (string, []interface{}) {
+	if p.any {
+		return "", nil
+	}
+	// An empty list matches no row in SQLite.
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.revisions)), ", ")
+	return " AND revision IN (" + placeholders + ")", p.revisions
+}
+
+// checkPrecondition checks the result of an update or a delete made under the
+// precondition of a request. If no row was changed although the row still
+// exists, its revision did not match and 412 Precondition Failed is written.
+// It reports whether the handler should go on; missing rows are left to the
+// handler.
+func (h *Handler) checkPrecondition(w http.ResponseWriter, r *http.Request, p precondition, result sql.Result,
+	existsQuery string, args ...interface{}) bool {
+	if p.any {
+		return true
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		h.requestLogger(r).Error("failed to count changed rows", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return false
+	}
+	if changed > 0 {
+		return true
+	}
+
+	rows, err := h.db.Query(existsQuery, args...)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query row", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return false
+	}
+	defer rows.Close()
+	if rows.Next() {
+		h.requestLogger(r).Info("precondition failed", zap.Strings("if_match", r.Header.Values("If-Match")))
+		WriteError(w, r, errPreconditionFailed)
+		return false
+	}
+	return true
+}
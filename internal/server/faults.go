@@ -0,0 +1,260 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+// FaultInjectedHeader names the faults injected in a response, so that they
+// are not mistaken for bugs.
+const FaultInjectedHeader = "X-Fault-Injected"
+
+// FaultRule injects faults in a fraction of the requests of a route.
+type FaultRule struct {
+	// Method is the HTTP method of the route.
+	Method string `json:"method"`
+	// Path is the path template of the route.
+	Path string `json:"path"`
+	// Probability is the fraction of the requests in which faults are injected.
+	Probability float64 `json:"probability"`
+	// LatencyMs delays the requests by this many milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+	// Status answers the requests with an error of this HTTP status.
+	Status int `json:"status"`
+	// Hang holds the requests until the client gives up.
+	Hang bool `json:"hang"`
+	// Drop closes the connection without a response.
+	Drop bool `json:"drop"`
+}
+
+// FaultInjection is the state of fault injection, changed at runtime by admins.
+type FaultInjection struct {
+	// Enabled turns fault injection on.
+	Enabled bool `json:"enabled"`
+	// Seed of the random decisions. A zero seed is replaced by a random one,
+	// returned so that the faults can be reproduced.
+	Seed int64 `json:"seed"`
+	// Rules are the faults injected per route.
+	Rules []FaultRule `json:"rules"`
+}
+
+// validate reports the invalid fields of the state.
+func (f FaultInjection) validate() *APIError {
+	var details []FieldError
+	routes := map[string]bool{}
+	for i, rule := range f.Rules {
+		field := fmt.Sprintf("rules.%d", i)
+		route := routeName(rule.Method, rule.Path)
+		switch {
+		case rule.Method == "" || rule.Path == "":
+			details = append(details, FieldError{Field: field, Message: "must have a method and a path"})
+		case routes[route]:
+			details = append(details, FieldError{Field: field, Message: "duplicates the rule of route " + route})
+		case rule.Probability < 0 || rule.Probability > 1:
+			details = append(details, FieldError{Field: field + ".probability", Message: "must be between 0 and 1"})
+		case rule.LatencyMs < 0:
+			details = append(details, FieldError{Field: field + ".latency_ms", Message: "must not be negative"})
+		case rule.Status != 0 && (rule.Status < 400 || rule.Status > 599):
+			details = append(details, FieldError{Field: field + ".status", Message: "must be an error status"})
+		case rule.LatencyMs == 0 && rule.Status == 0 && !rule.Hang && !rule.Drop:
+			details = append(details, FieldError{Field: field, Message: "must inject at least one fault"})
+		}
+		routes[route] = true
+	}
+	if len(details) > 0 {
+		return newValidationError(details...)
+	}
+	return nil
+}
+
+// names lists the faults of the rule.
+func (f FaultRule) names() []string {
+	var names []string
+	if f.LatencyMs > 0 {
+		names = append(names, "latency")
+	}
+	switch {
+	case f.Drop:
+		names = append(names, "drop")
+	case f.Hang:
+		names = append(names, "hang")
+	case f.Status != 0:
+		names = append(names, "status")
+	}
+	return names
+}
+
+// faultRule is a rule along with the source of its random decisions. Every rule
+// has its own source, so that its decisions only depend on the requests of its
+// route.
+type faultRule struct {
+	FaultRule
+	random *rand.Rand
+}
+
+// faultInjector injects the configured faults in the requests of their routes.
+type faultInjector struct {
+	mu    sync.Mutex
+	state FaultInjection
+	rules map[string]*faultRule
+}
+
+// newFaultInjector creates the injector from the configuration.
+func newFaultInjector(cfg config.FaultInjection) (*faultInjector, error) {
+	state := FaultInjection{Enabled: cfg.Enabled, Seed: cfg.Seed, Rules: []FaultRule{}}
+	for _, rule := range cfg.Rules {
+		state.Rules = append(state.Rules, FaultRule{
+			Method:      rule.Method,
+			Path:        rule.Path,
+			Probability: rule.Probability,
+			LatencyMs:   rule.Latency.Milliseconds(),
+			Status:      rule.Status,
+			Hang:        rule.Hang,
+			Drop:        rule.Drop,
+		})
+	}
+	if err := state.validate(); err != nil {
+		return nil, fmt.Errorf("invalid fault injection %s: %s", err.Details[0].Field, err.Details[0].Message)
+	}
+	injector := &faultInjector{}
+	injector.set(state)
+	return injector, nil
+}
+
+// set replaces the state of the injector, seeding the rules again.
+func (i *faultInjector) set(state FaultInjection) FaultInjection {
+	if state.Seed == 0 {
+		state.Seed = time.Now().UnixNano()
+	}
+	if state.Rules == nil {
+		state.Rules = []FaultRule{}
+	}
+	rules := map[string]*faultRule{}
+	for n, rule := range state.Rules {
+		rule.Method = strings.ToUpper(rule.Method)
+		state.Rules[n] = rule
+		rules[routeName(rule.Method, rule.Path)] = &faultRule{
+			FaultRule: rule,
+			random:    rand.New(rand.NewSource(state.Seed + int64(n))),
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.state, i.rules = state, rules
+	return state
+}
+
+// get returns the state of the injector.
+func (i *faultInjector) get() FaultInjection {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.state
+}
+
+// pick returns the faults to inject in a request of the route, if any.
+func (i *faultInjector) pick(route string) *FaultRule {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.state.Enabled {
+		return nil
+	}
+	rule, ok := i.rules[route]
+	if !ok || rule.random.Float64() >= rule.Probability {
+		return nil
+	}
+	return &rule.FaultRule
+}
+
+// FaultInjectionMiddleware injects the configured faults in the requests of
+// their routes, when fault injection is enabled.
+func (h *Handler) FaultInjectionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeName(r.Method, routeTemplate(r))
+		fault := h.faults.pick(route)
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.requestLogger(r).Warn("injecting fault", zap.String("route", route), zap.Any("fault", fault))
+		names := fault.names()
+		for _, name := range names {
+			h.metrics.injectedFaults.inc(route, name)
+		}
+		w.Header().Set(FaultInjectedHeader, strings.Join(names, ","))
+
+		if fault.LatencyMs > 0 {
+			select {
+			case <-time.After(time.Duration(fault.LatencyMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case fault.Drop:
+			// Aborting the handler closes the connection without a response.
+			panic(http.ErrAbortHandler)
+		case fault.Hang:
+			<-r.Context().Done()
+			WriteError(w, r, errTimeout)
+		case fault.Status != 0:
+			WriteError(w, r, NewAPIError(fault.Status, CodeInjectedFault, "Injected fault"))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// GetFaultsHandler returns the state of fault injection.
+func (h *Handler) GetFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeFaults(w, r, h.faults.get())
+}
+
+// UpdateFaultsHandler replaces the state of fault injection, e.g. to enable or
+// disable it during a test run.
+func (h *Handler) UpdateFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	var state FaultInjection
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+	if err := state.validate(); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	state = h.faults.set(state)
+	h.requestLogger(r).Warn("fault injection changed", zap.Bool("enabled", state.Enabled),
+		zap.Int64("seed", state.Seed), zap.Int("rules", len(state.Rules)))
+	h.writeFaults(w, r, state)
+}
+
+// writeFaults writes the state of fault injection.
+func (h *Handler) writeFaults(w http.ResponseWriter, r *http.Request, state FaultInjection) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
@@ -19,14 +19,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Credentials represents the structure for the credentials provided by the user.
@@ -41,6 +42,8 @@ type Credentials struct {
 type TokenResponse struct {
 	// JWT Token to be used for authenticated requests.
 	Token string `json:"token"`
+	// RefreshToken is used once to renew the JWT token before it expires.
+	RefreshToken string `json:"refresh_token"`
 }
 
 // Error implements error.
@@ -60,6 +63,10 @@ type Service struct {
 	CreatedAt time.Time `json:"created_at"`
 	// Timestamp when the service was last updated.
 	UpdatedAt time.Time `json:"updated_at"`
+	// Timestamp when the service was deleted, if it was.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Revision of the service, returned as its ETag.
+	Revision int64 `json:"-"`
 }
 
 // ServiceVersion represents a version of a specific service.
@@ -74,6 +81,17 @@ type ServiceVersion struct {
 	CreatedAt time.Time `json:"created_at"`
 	// Timestamp when the service version was last updated.
 	UpdatedAt time.Time `json:"updated_at"`
+	// Timestamp when the service version was deleted, if it was.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Revision of the service version, returned as its ETag.
+	Revision int64 `json:"-"`
+}
+
+// serviceVersionSortColumns maps the sortable service version fields to their columns.
+var serviceVersionSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"version":    "version",
 }
 
 // Opts are the options used to create a new handler.
@@ -127,56 +145,123 @@ func (n *nullString) UnmarshalJSON(data []byte) error {
 
 // Handler instance.
 type Handler struct {
-	jwtSecret       string
-	jwtTokenTimeout time.Duration
-	username        string
-	password        string
+	jwtSecret         string
+	jwtTokenTimeout   time.Duration
+	jwtIssuer         string
+	jwtAudience       string
+	jwtClockSkew      time.Duration
+	refreshTimeout    time.Duration
+	keys              *keySet
+	trustedIssuers    map[string]*trustedIssuer
+	permissions       rolePermissions
+	logins            *loginLimiter
+	trustForwardedFor bool
+	dummyPasswordHash []byte
+	rateLimiter       *rateLimiter
+	faults            *faultInjector
+	cachePolicies     *cachePolicies
+	idempotencyWindow time.Duration
+	purgeAfter        time.Duration
+	purgeInterval     time.Duration
+	metrics           *metrics
 
 	db     *sql.DB
 	logger *zap.Logger
 }
 
 // NewHandler creates an instance of the handlers for the application server.
+// The admin account from the configuration is created if it does not exist.
 func NewHandler(opts Opts) (*Handler, error) {
-	return &Handler{
-		jwtSecret:       opts.Config.JWTSecret,
-		jwtTokenTimeout: opts.Config.JWTTokenTimeout,
-		username:        opts.Config.Username,
-		password:        opts.Config.Password,
+	permissions, err := newRolePermissions(opts.Config.Roles)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := newKeySet(opts.Config.JWTKeys, opts.Config.JWTSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		if opts.Config.JWTSecret == "" {
+			return nil, errors.New("no key to sign tokens with: configure jwt_keys and jwt_signing_key")
+		}
+		opts.Logger.Warn("signing tokens with the shared jwt_secret, which every verifier can forge tokens with: " +
+			"configure jwt_keys and jwt_signing_key instead")
+	}
+	trustedIssuers, err := newTrustedIssuers(opts.Config.TrustedIssuers, opts.Config.JWTIssuer)
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, err := newRateLimiter(opts.Config.RateLimiting)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := newFaultInjector(opts.Config.FaultInjection)
+	if err != nil {
+		return nil, err
+	}
+	cachePolicies, err := newCachePolicies(opts.Config.CacheControl)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		jwtSecret:         opts.Config.JWTSecret,
+		jwtTokenTimeout:   opts.Config.JWTTokenTimeout,
+		jwtIssuer:         opts.Config.JWTIssuer,
+		jwtAudience:       opts.Config.JWTAudience,
+		jwtClockSkew:      opts.Config.JWTClockSkew,
+		refreshTimeout:    opts.Config.RefreshTokenTimeout,
+		keys:              keys,
+		trustedIssuers:    trustedIssuers,
+		permissions:       permissions,
+		logins:            newLoginLimiter(opts.Config.LoginProtection),
+		trustForwardedFor: opts.Config.LoginProtection.TrustForwardedFor,
+		rateLimiter:       rateLimiter,
+		faults:            faults,
+		cachePolicies:     cachePolicies,
+		idempotencyWindow: opts.Config.Idempotency.Window,
+		purgeAfter:        opts.Config.SoftDelete.PurgeAfter,
+		purgeInterval:     opts.Config.SoftDelete.PurgeInterval,
+		metrics:           newMetrics(),
 
 		db:     opts.Database,
 		logger: opts.Logger.With(zap.String("component", "handler")),
-	}, nil
+	}
+	// Unknown users are checked against the hash of a random password, so that
+	// rejecting them takes as long as rejecting a wrong password.
+	dummyPasswordHash, err := hashPassword(uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+	h.dummyPasswordHash = []byte(dummyPasswordHash)
+	if err := h.bootstrapAdmin(opts.Config.Username, opts.Config.Password); err != nil {
+		return nil, err
+	}
+	return h, nil
 }
 
-// AuthenticateToken authenticates a request using a bearer token in the Authorization header.
-func (h *Handler) AuthenticateToken(r *http.Request) error {
-	// Parse auth and get the token
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		h.logger.Warn("missing Authorization header")
-		return errors.New("missing authorization header")
-	}
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-		h.logger.Warn("invalid Authorization header format")
-		return errors.New("invalid authorization header format")
-	}
-	tokenStr := parts[1]
-
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			h.logger.Warn("unexpected signing method")
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(h.jwtSecret), nil
-	})
-	if err != nil || !token.Valid {
-		h.logger.Warn("invalid token", zap.Error(err))
-		return errors.New("invalid token")
+// requestLogger returns the handler logger annotated with the ID of the request
+// and the username of the authenticated caller, if any.
+func (h *Handler) requestLogger(r *http.Request) *zap.Logger {
+	logger := h.logger.With(zap.String("request_id", RequestIDFromContext(r.Context())))
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		logger = logger.With(zap.String("username", claims.Username))
 	}
+	return logger
+}
 
-	return nil
+// HealthHandler reports whether the server is able to serve requests.
+func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.PingContext(r.Context()); err != nil {
+		h.requestLogger(r).Error("database is unreachable", zap.Error(err))
+		WriteError(w, r, errUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
 }
 
 // GenerateTokenHandler handles token generation requests.
@@ -185,77 +270,97 @@ func (h *Handler) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
 		return
 	}
 
-	// Validate credentials against application username and password
-	if creds.Username != h.username || creds.Password != h.password {
-		h.logger.Warn("invalid login attempt", zap.String("username", creds.Username))
-		if creds.Password != h.password {
-			http.Error(w, fmt.Sprintf(`{"error": "password is not equal to %s"}`, h.password), http.StatusUnauthorized)
-		} else {
-			http.Error(w, `{"error": "Invalid username or password"}`, http.StatusUnauthorized)
-		}
+	// Reject the attempt without checking the password while the username or
+	// the client is locked out, otherwise reserve it until it is checked.
+	ip := h.clientIP(r)
+	username := loginUsername(creds.Username)
+	if retryAfter := h.logins.reserve(username, ip); retryAfter > 0 {
+		h.recordLoginAttempt(r, username, ip, loginLockedOut)
+		writeTooManyLogins(w, r, retryAfter)
 		return
 	}
 
-	// Create a new JWT token with an expiration time.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": creds.Username,
-		"exp":      time.Now().Add(h.jwtTokenTimeout).Unix(),
-	})
+	// Validate credentials against the password hash of an enabled user.
+	// Unknown and disabled users are compared with a dummy hash, so that every
+	// failure takes as long and returns the same error.
+	var passwordHash, role string
+	err = h.db.QueryRow("SELECT password_hash, role FROM users WHERE username = ? AND NOT disabled",
+		creds.Username).Scan(&passwordHash, &role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logins.release(username, ip)
+		h.requestLogger(r).Error("failed to query user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	hash := []byte(passwordHash)
+	if err != nil {
+		hash = h.dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(creds.Password)) != nil || err != nil {
+		h.logins.recordFailure(username, ip)
+		h.recordLoginAttempt(r, username, ip, loginInvalidCredentials)
+		WriteError(w, r, errInvalidCredentials)
+		return
+	}
+	h.logins.recordSuccess(username, ip)
+	h.recordLoginAttempt(r, username, ip, loginSucceeded)
 
-	// Sign the token using the JWT secret key.
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	// Issue an access token along with a refresh token to renew it.
+	response, err := h.issueTokens(r.Context(), h.db, creds.Username, role, "")
 	if err != nil {
-		h.logger.Error("failed to sign token", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to issue tokens", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
-	// Return the generated token in the response.
-	response := TokenResponse{Token: tokenString}
+	// Return the generated tokens in the response.
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		h.logger.Error("unable to encode response: %w", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response: %w", zap.Error(err))
 	}
 }
 
 // CreateServiceHandler handles the creation of a new service in the catalog.
 // It takes service information from the request and inserts it into the database.
 func (h *Handler) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Decode the JSON payload from the request body.
 	var newService Service
 	err := json.NewDecoder(r.Body).Decode(&newService)
 	if err != nil {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
 		return
 	}
 
 	// Generate a new UUID v1 for the service ID.
 	id, err := uuid.NewUUID()
 	if err != nil {
-		h.logger.Error("failed to generate UUID for new service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to generate UUID for new service", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	newService.ID = id.String()
 
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
 	// Prepare an SQL statement to insert the new service.
 	//nolint:lll
-	stmt, err := h.db.Prepare("INSERT INTO services (id, name, description, created_at, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)")
+	stmt, err := tx.PrepareContext(r.Context(), "INSERT INTO services (id, name, description, created_at, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)")
 	if err != nil {
-		h.logger.Error("failed to prepare statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to prepare statement", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer stmt.Close()
@@ -263,43 +368,104 @@ func (h *Handler) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
 	// Execute the SQL statement with the service details.
 	_, err = stmt.Exec(newService.ID, newService.Name, newService.Description)
 	if err != nil {
-		h.logger.Error("failed to insert service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to insert service", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
-	row := h.db.QueryRow("SELECT id, name, description, created_at, updated_at FROM services WHERE id = ?", newService.ID)
+	//nolint:lll
+	row := tx.QueryRowContext(r.Context(), "SELECT id, name, description, created_at, updated_at, revision FROM services WHERE id = ?", newService.ID)
 
 	var service Service
-	err = row.Scan(&service.ID, &service.Name, &service.Description, &service.CreatedAt, &service.UpdatedAt)
+	err = row.Scan(&service.ID, &service.Name, &service.Description, &service.CreatedAt, &service.UpdatedAt,
+		&service.Revision)
 	if err != nil {
-		h.logger.Error("failed to fetch inserted service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to fetch inserted service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	err = h.commitWithAudit(r, tx, auditEntry{auditCreate, auditService, service.ID, nil, service})
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit service creation", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
 	// Set the response status to 201 Created and encode the new service as JSON.
+	setETag(w, service.Revision)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": service})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
-// ListServicesHandler lists all the available services in the catalog.
-// It retrieves service information from the database and returns it in the response.
+// ListServicesHandler lists the available services in the catalog one page at a time.
+// It retrieves service information from the database, optionally narrowed down by a
+// free-text search query, and returns it in the response.
 func (h *Handler) ListServicesHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
 		return
 	}
 
-	// Query the database to retrieve all services.
-	rows, err := h.db.Query("SELECT id, name, description, created_at, updated_at FROM services")
+	asOf, err := parseInstant(r, "as_of")
 	if err != nil {
-		h.logger.Error("failed to query services", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		WriteError(w, r, err)
+		return
+	}
+
+	// Services are ordered by creation time unless a search query is given, in
+	// which case only matching services are returned, ordered by relevance.
+	// Deleted services are hidden unless requested. Past services are read from
+	// their history, which is not indexed for search.
+	table, args := catalogTable("services", asOf)
+	from := table + " s"
+	orderBy := "s.created_at"
+	conditions := []string{}
+	if !includeDeleted(r) {
+		conditions = append(conditions, "s.deleted_at IS NULL")
+	}
+	if q := r.URL.Query().Get("q"); q != "" {
+		if asOf != "" {
+			WriteError(w, r, newValidationError(FieldError{Field: "q", Message: "cannot be combined with as_of"}))
+			return
+		}
+		match, err := ftsQuery(q)
+		if err != nil {
+			h.requestLogger(r).Warn("invalid search query", zap.Error(err))
+			WriteError(w, r, err)
+			return
+		}
+		from = "services_fts JOIN services s ON s.rowid = services_fts.rowid"
+		orderBy = "bm25(services_fts, 10.0, 1.0)"
+		conditions = append(conditions, "services_fts MATCH ?")
+		args = append(args, match)
+	}
+	if len(conditions) > 0 {
+		from += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// Count the services so clients can tell how many pages there are.
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM "+from, args...).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count services", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Query the database to retrieve the requested page of services, using the
+	// ID as a tie-breaker to keep pages stable.
+	query := fmt.Sprintf(`SELECT s.id, s.name, s.description, s.created_at, s.updated_at, s.deleted_at FROM %s
+		ORDER BY %s, s.id LIMIT ? OFFSET ?`, from, orderBy)
+	rows, err := h.db.Query(query, append(args, limit, offset(page, limit))...)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query services", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer rows.Close()
@@ -308,63 +474,66 @@ func (h *Handler) ListServicesHandler(w http.ResponseWriter, r *http.Request) {
 	var services []Service
 	for rows.Next() {
 		var s Service
-		err = rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt)
+		err = rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt)
 		if err != nil {
-			h.logger.Error("failed to scan service", zap.Error(err))
-			http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+			h.requestLogger(r).Error("failed to scan service", zap.Error(err))
+			WriteError(w, r, errInternal)
 			return
 		}
 		services = append(services, s)
 	}
 
-	// Return the list of services in the response.
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]interface{}{"items": services})
-	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
-	}
+	// Return the page of services along with the pagination metadata.
+	h.writeCollection(w, r, map[string]interface{}{
+		"items":      services,
+		"pagination": newPagination(page, limit, total),
+	})
 }
 
 // GetServiceHandler retrieves a specific service by its ID.
 // It takes the service ID from the URL parameters and retrieves the service details from the database.
 func (h *Handler) GetServiceHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
 
-	// Query the database to get the service details by ID.
+	asOf, err := parseInstant(r, "as_of")
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	// Query the database to get the service details by ID, as of the requested
+	// time if any.
 	var service Service
-	err := h.db.QueryRow("SELECT id, name, description, created_at, updated_at FROM services WHERE id = ?",
-		serviceID).Scan(&service.ID, &service.Name, &service.Description, &service.CreatedAt, &service.UpdatedAt)
+	table, args := catalogTable("services", asOf)
+	err = h.db.QueryRow(`SELECT id, name, description, created_at, updated_at, revision FROM `+table+`
+		WHERE id = ? AND deleted_at IS NULL`, append(args, serviceID)...).Scan(&service.ID, &service.Name,
+		&service.Description, &service.CreatedAt, &service.UpdatedAt, &service.Revision)
 	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, r, errServiceNotFound)
 		return
 	} else if err != nil {
-		h.logger.Error("failed to query service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
-	// Return the service details in the response.
+	// Return the service details in the response, unless the copy of the
+	// client is still fresh.
+	if h.checkNotModified(w, r, etag(service.Revision), service.UpdatedAt) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": service})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
 // UpdateServiceHandler updates an existing service in the catalog.
 // It takes the service ID from the URL parameters and the updated data from the request body.
 func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
@@ -375,8 +544,8 @@ func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 	// Decode the JSON payload from the request body.
 	err := json.NewDecoder(r.Body).Decode(&updatedService)
 	if err != nil {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
 		return
 	}
 
@@ -392,39 +561,63 @@ func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 		updateFields = append(updateFields, "description = ?")
 		values = append(values, updatedService.Description)
 	}
-	updateFields = append(updateFields, "updated_at = CURRENT_TIMESTAMP")
+	updateFields = append(updateFields, "revision = revision + 1", "updated_at = CURRENT_TIMESTAMP")
 	values = append(values, serviceID)
-	query := fmt.Sprintf("UPDATE services SET %s WHERE id = ?", strings.Join(updateFields, ", "))
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := loadService(r.Context(), tx, serviceID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Only update the service if its revision matches the If-Match header.
+	precondition := parseIfMatch(r)
+	condition, conditionValues := precondition.clause()
+	values = append(values, conditionValues...)
+	query := fmt.Sprintf("UPDATE services SET %s WHERE id = ? AND deleted_at IS NULL%s", strings.Join(updateFields, ", "),
+		condition)
 
 	// Prepare an SQL statement to update the service.
-	stmt, err := h.db.Prepare(query)
+	stmt, err := tx.PrepareContext(r.Context(), query)
 	if err != nil {
-		h.logger.Error("failed to prepare update statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to prepare update statement", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer stmt.Close()
 
 	// Execute the SQL statement with the updated service details.
-	_, err = stmt.Exec(values...)
+	result, err := stmt.Exec(values...)
 	if err != nil {
-		h.logger.Error("failed to update service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to update service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if !h.checkPrecondition(w, r, precondition, result, "SELECT 1 FROM services WHERE id = ? AND deleted_at IS NULL",
+		serviceID) {
 		return
 	}
 
 	// Query the database to get the service details by ID.
 	var name nullString
 	var description string
-	err = h.db.QueryRow("SELECT id, name, description, created_at, updated_at FROM services WHERE id = ?",
-		serviceID).Scan(&updatedService.ID, &name, &description,
-		&updatedService.CreatedAt, &updatedService.UpdatedAt)
+	err = tx.QueryRowContext(r.Context(), `SELECT id, name, description, created_at, updated_at, revision FROM services
+		WHERE id = ? AND deleted_at IS NULL`, serviceID).Scan(&updatedService.ID, &name, &description,
+		&updatedService.CreatedAt, &updatedService.UpdatedAt, &updatedService.Revision)
 	if errors.Is(err, sql.ErrNoRows) {
-		http.Error(w, `{"error": "Service not found"}`, http.StatusNotFound)
+		WriteError(w, r, errServiceNotFound)
 		return
 	} else if err != nil {
-		h.logger.Error("failed to query service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	if len(strings.TrimSpace(updatedService.Name.String)) == 0 {
@@ -433,41 +626,109 @@ func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 	if len(strings.TrimSpace(updatedService.Description)) == 0 {
 		updatedService.Description = description
 	}
+	after, err := loadService(r.Context(), tx, serviceID)
+	if err == nil {
+		err = h.commitWithAudit(r, tx, auditEntry{auditUpdate, auditService, serviceID, before, after})
+	}
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit service update", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
 
 	// Return a 200 OK response indicating the service was successfully updated.
+	setETag(w, updatedService.Revision)
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": updatedService})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
 // DeleteServiceHandler deletes a specific service from the catalog.
-// It takes the service ID from the URL parameters and removes the service from the database.
+// It takes the service ID from the URL parameters and marks the service as deleted, so that
+// it can be restored until it is purged. A service with versions is only deleted along with
+// them, when the cascade query parameter is set.
 func (h *Handler) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
+	cascade := false
+	if value := r.URL.Query().Get("cascade"); value != "" {
+		var err error
+		cascade, err = strconv.ParseBool(value)
+		if err != nil {
+			WriteError(w, r, newValidationError(FieldError{Field: "cascade", Message: "must be true or false"}))
+			return
+		}
+	}
 
-	// Prepare an SQL statement to delete the service by ID.
-	stmt, err := h.db.Prepare("DELETE FROM services WHERE id = ?")
+	tx, err := h.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		h.logger.Error("failed to prepare delete statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := loadService(r.Context(), tx, serviceID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Refuse to leave the versions of the service behind, unless cascading.
+	var versions int
+	err = tx.QueryRowContext(r.Context(),
+		"SELECT COUNT(*) FROM service_versions WHERE service_id = ? AND deleted_at IS NULL", serviceID).Scan(&versions)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count service versions", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if versions > 0 && !cascade {
+		h.requestLogger(r).Info("service still has versions", zap.Int("versions", versions))
+		WriteError(w, r, errServiceHasVersions)
 		return
 	}
-	defer stmt.Close()
 
-	// Execute the SQL statement to delete the service.
-	_, err = stmt.Exec(serviceID)
+	// Delete the service by ID, if its revision matches the If-Match header.
+	precondition := parseIfMatch(r)
+	condition, conditionValues := precondition.clause()
+	result, err := tx.ExecContext(r.Context(),
+		"UPDATE services SET deleted_at = CURRENT_TIMESTAMP, revision = revision + 1 WHERE id = ? AND deleted_at IS NULL"+
+			condition, append([]interface{}{serviceID}, conditionValues...)...)
 	if err != nil {
-		h.logger.Error("failed to delete service", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to delete service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Delete the versions along with the service, marking them so that they
+	// are restored along with it, and record the deletion.
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		if versions > 0 {
+			_, err = tx.ExecContext(r.Context(), `UPDATE service_versions
+				SET deleted_at = CURRENT_TIMESTAMP, deleted_with_service = 1, revision = revision + 1
+				WHERE service_id = ? AND deleted_at IS NULL`, serviceID)
+			if err != nil {
+				h.requestLogger(r).Error("failed to delete service versions", zap.Error(err))
+				WriteError(w, r, errInternal)
+				return
+			}
+		}
+		after, err := loadService(r.Context(), tx, serviceID)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, auditEntry{auditDelete, auditService, serviceID, before, after})
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit service deletion", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+	}
+	if !h.checkPrecondition(w, r, precondition, result, "SELECT 1 FROM services WHERE id = ? AND deleted_at IS NULL",
+		serviceID) {
 		return
 	}
 
@@ -478,11 +739,6 @@ func (h *Handler) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
 // CreateServiceVersionHandler handles the creation of a new version for a specific service.
 // It takes the service ID from the URL and the version data from the request body, and inserts it into the database.
 func (h *Handler) CreateServiceVersionHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
@@ -492,16 +748,16 @@ func (h *Handler) CreateServiceVersionHandler(w http.ResponseWriter, r *http.Req
 	// Decode the JSON payload from the request body.
 	err := json.NewDecoder(r.Body).Decode(&newVersion)
 	if err != nil {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
 		return
 	}
 
 	// Generate a new UUID version ID.
 	id, err := uuid.NewUUID()
 	if err != nil {
-		h.logger.Error("failed to generate UUID for new service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to generate UUID for new service version", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	newVersion.ID = id.String()
@@ -509,56 +765,126 @@ func (h *Handler) CreateServiceVersionHandler(w http.ResponseWriter, r *http.Req
 	version := newVersion.Version
 
 	if len(version) > 16 {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Version cannot be longer than 16 characters "}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errVersionTooLong)
 		return
 	}
 
-	// Prepare an SQL statement to insert the new version.
-	//nolint:lll
-	stmt, err := h.db.Prepare("INSERT INTO service_versions (id, service_id, version, created_at, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)")
+	tx, err := h.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		h.logger.Error("failed to prepare statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Prepare an SQL statement to insert the new version, only if its service
+	// exists and is not deleted.
+	stmt, err := tx.PrepareContext(r.Context(), `
+		INSERT INTO service_versions (id, service_id, version, created_at, updated_at)
+		SELECT ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		WHERE EXISTS (SELECT 1 FROM services WHERE id = ? AND deleted_at IS NULL)`)
+	if err != nil {
+		h.requestLogger(r).Error("failed to prepare statement", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer stmt.Close()
 
 	// Execute the SQL statement with the version details.
-	_, err = stmt.Exec(newVersion.ID, newVersion.ServiceID, newVersion.Version)
+	result, err := stmt.Exec(newVersion.ID, newVersion.ServiceID, newVersion.Version, newVersion.ServiceID)
 	if err != nil {
-		h.logger.Error("failed to insert service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to insert service version", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		h.requestLogger(r).Error("failed to count inserted rows", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	} else if inserted == 0 {
+		WriteError(w, r, errServiceNotFound)
+		return
+	}
+	after, err := loadServiceVersion(r.Context(), tx, newVersion.ServiceID, newVersion.ID)
+	if err == nil {
+		err = h.commitWithAudit(r, tx, auditEntry{auditCreate, auditServiceVersion, newVersion.ID, nil, after})
+	}
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit service version creation", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
 	// Set the response status to 201 Created and encode the new version as JSON.
+	setETag(w, after.Revision)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": newVersion})
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": after})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
-// ListServiceVersionsHandler lists all the versions for a specific service.
-// It retrieves version information from the database for the given service ID.
+// ListServiceVersionsHandler lists the versions for a specific service one page at a time.
+// It retrieves version information from the database for the given service ID, optionally
+// filtered by a version prefix and sorted by the requested field.
 func (h *Handler) ListServiceVersionsHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
 
-	// Query the database to retrieve all versions for the given service.
-	//nolint:lll
-	rows, err := h.db.Query("SELECT id, service_id, version, created_at, updated_at FROM service_versions WHERE service_id = ?", serviceID)
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+	column, direction, err := parseSort(r, serviceVersionSortColumns, "created_at")
+	if err != nil {
+		h.requestLogger(r).Warn("invalid sort parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	asOf, err := parseInstant(r, "as_of")
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	// Restrict the results to versions starting with the requested prefix,
+	// hiding the deleted versions unless requested, as of the requested time if
+	// any.
+	table, args := catalogTable("service_versions", asOf)
+	where := "WHERE service_id = ?"
+	args = append(args, serviceID)
+	if !includeDeleted(r) {
+		where += " AND deleted_at IS NULL"
+	}
+	if prefix := r.URL.Query().Get("version"); prefix != "" {
+		where += ` AND version LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(prefix)+"%")
+	}
+
+	// Count the matching versions so clients can tell how many pages there are.
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM "+table+" "+where, args...).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count service versions", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Query the database to retrieve the requested page of versions for the given
+	// service, using the ID as a tie-breaker to keep pages stable.
+	//nolint:gosec // column and direction are restricted to known values by parseSort
+	query := fmt.Sprintf(`SELECT id, service_id, version, created_at, updated_at, deleted_at FROM %s %s
+		ORDER BY %s %s, id %s LIMIT ? OFFSET ?`, table, where, column, direction, direction)
+	rows, err := h.db.Query(query, append(args, limit, offset(page, limit))...)
 	if err != nil {
-		h.logger.Error("failed to query service versions", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to query service versions", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer rows.Close()
@@ -567,161 +893,213 @@ func (h *Handler) ListServiceVersionsHandler(w http.ResponseWriter, r *http.Requ
 	var versions []ServiceVersion
 	for rows.Next() {
 		var v ServiceVersion
-		err = rows.Scan(&v.ID, &v.ServiceID, &v.Version, &v.CreatedAt, &v.UpdatedAt)
+		err = rows.Scan(&v.ID, &v.ServiceID, &v.Version, &v.CreatedAt, &v.UpdatedAt, &v.DeletedAt)
 		if err != nil {
-			h.logger.Error("failed to scan service version", zap.Error(err))
-			http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+			h.requestLogger(r).Error("failed to scan service version", zap.Error(err))
+			WriteError(w, r, errInternal)
 			return
 		}
 		versions = append(versions, v)
 	}
 
-	// Return the list of versions in the response.
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]interface{}{"items": versions})
-	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
-	}
+	// Return the page of versions along with the pagination metadata.
+	h.writeCollection(w, r, map[string]interface{}{
+		"items":      versions,
+		"pagination": newPagination(page, limit, total),
+	})
 }
 
 // GetServiceVersionHandler retrieves a specific version for a given service by its ID.
 // It takes the service ID and version ID from the URL and retrieves the version details from the database.
 func (h *Handler) GetServiceVersionHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID and version ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
 	versionID := vars["versionId"]
 
-	// Query the database to get the version details by ID.
+	asOf, err := parseInstant(r, "as_of")
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	// Query the database to get the version details by ID, as of the requested
+	// time if any.
 	var version ServiceVersion
-	//nolint:lll
-	err := h.db.QueryRow("SELECT id, service_id, version, created_at, updated_at FROM service_versions WHERE id = ? AND service_id = ?",
-		versionID, serviceID).Scan(&version.ID, &version.ServiceID, &version.Version, &version.CreatedAt, &version.UpdatedAt)
+	table, args := catalogTable("service_versions", asOf)
+	err = h.db.QueryRow(`SELECT id, service_id, version, created_at, updated_at, revision FROM `+table+`
+		WHERE id = ? AND service_id = ? AND deleted_at IS NULL`, append(args, versionID, serviceID)...).Scan(
+		&version.ID, &version.ServiceID, &version.Version, &version.CreatedAt, &version.UpdatedAt, &version.Revision)
 	if errors.Is(err, sql.ErrNoRows) {
-		http.Error(w, `{"error": "Service version not found"}`, http.StatusNotFound)
+		WriteError(w, r, errVersionNotFound)
 		return
 	} else if err != nil {
-		h.logger.Error("failed to query service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to query service version", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
-	// Return the version details in the response.
+	// Return the version details in the response, unless the copy of the
+	// client is still fresh.
+	if h.checkNotModified(w, r, etag(version.Revision), version.UpdatedAt) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": version})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
 // UpdateServiceVersionHandler updates an existing version for a specific service.
 // It takes the service ID and version ID from the URL and the updated data from the request body.
 func (h *Handler) UpdateServiceVersionHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID and version ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
 	versionID := vars["versionId"]
 
 	var updatedVersion ServiceVersion
-	updatedVersion.ID = versionID
-	updatedVersion.ServiceID = serviceID
 
 	// Decode the JSON payload from the request body.
 	err := json.NewDecoder(r.Body).Decode(&updatedVersion)
 	if err != nil {
-		h.logger.Error("invalid request payload", zap.Error(err))
-		http.Error(w, `{"error": "Invalid request payload"}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
 		return
 	}
 	version := updatedVersion.Version
 	if len(version) > 16 {
-		h.logger.Error("Invalid request payload")
-		http.Error(w, `{"error": "Version cannot be longer than 16 characters "}`, http.StatusBadRequest)
+		h.requestLogger(r).Error("Invalid request payload")
+		WriteError(w, r, errVersionTooLong)
 		return
 	}
-	// Prepare an SQL statement to update the service version.
-	//nolint:lll
-	stmt, err := h.db.Prepare("UPDATE service_versions SET ID = ?, version = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND service_id = ?")
+	tx, err := h.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		h.logger.Error("failed to prepare update statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := loadServiceVersion(r.Context(), tx, serviceID, versionID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service version", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
-	defer stmt.Close()
 
-	// Generate a new UUID version ID.
-	id, err := uuid.NewUUID()
+	// Prepare an SQL statement to update the service version, if its revision
+	// matches the If-Match header. The version keeps its ID, so that it can be
+	// updated again at the same URL.
+	precondition := parseIfMatch(r)
+	condition, conditionValues := precondition.clause()
+	//nolint:lll
+	stmt, err := tx.PrepareContext(r.Context(), "UPDATE service_versions SET version = ?, revision = revision + 1, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND service_id = ? AND deleted_at IS NULL"+condition)
 	if err != nil {
-		h.logger.Error("failed to generate UUID for new service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to prepare update statement", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
+	defer stmt.Close()
 
 	// Execute the SQL statement with the updated version details.
-	_, err = stmt.Exec(id.String(), updatedVersion.Version, versionID, serviceID)
+	values := append([]interface{}{updatedVersion.Version, versionID, serviceID}, conditionValues...)
+	result, err := stmt.Exec(values...)
 	if err != nil {
-		h.logger.Error("failed to update service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to update service version", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if !h.checkPrecondition(w, r, precondition, result,
+		"SELECT 1 FROM service_versions WHERE id = ? AND service_id = ? AND deleted_at IS NULL", versionID, serviceID) {
 		return
 	}
 
 	// Query the database to get the version details by ID.
-	err = h.db.QueryRow("SELECT created_at, updated_at FROM service_versions WHERE id = ? AND service_id = ?",
-		id.String(), serviceID).Scan(&updatedVersion.CreatedAt, &updatedVersion.UpdatedAt)
-	if errors.Is(err, sql.ErrNoRows) {
-		http.Error(w, `{"error": "Service version not found"}`, http.StatusNotFound)
+	after, err := loadServiceVersion(r.Context(), tx, serviceID, versionID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service version", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
-	} else if err != nil {
-		h.logger.Error("failed to query service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+	} else if after == nil {
+		WriteError(w, r, errVersionNotFound)
+		return
+	}
+	err = h.commitWithAudit(r, tx, auditEntry{auditUpdate, auditServiceVersion, versionID, before, after})
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit service version update", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 
-	// Return a 200 OK response indicating the version was successfully updated.
+	// Return a 200 OK response with the updated version.
+	setETag(w, after.Revision)
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": updatedVersion})
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": after})
 	if err != nil {
-		h.logger.Error("unable to encode response", zap.Error(err))
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
 	}
 }
 
 // DeleteServiceVersionHandler deletes a specific version for a given service.
-// It takes the service ID and version ID from the URL and removes the version from the database.
+// It takes the service ID and version ID from the URL and marks the version as deleted, so that
+// it can be restored until it is purged.
 func (h *Handler) DeleteServiceVersionHandler(w http.ResponseWriter, r *http.Request) {
-	if err := h.AuthenticateToken(r); err != nil {
-		http.Error(w, `{"error": "Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
 	// Get the service ID and version ID from the URL path variables.
 	vars := mux.Vars(r)
 	serviceID := vars["serviceId"]
 	versionID := vars["versionId"]
 
-	// Prepare an SQL statement to delete the service version by ID.
-	stmt, err := h.db.Prepare("DELETE FROM service_versions WHERE id = ? AND service_id = ?")
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := loadServiceVersion(r.Context(), tx, serviceID, versionID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service version", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Prepare an SQL statement to delete the service version by ID, if its
+	// revision matches the If-Match header.
+	precondition := parseIfMatch(r)
+	condition, conditionValues := precondition.clause()
+	stmt, err := tx.PrepareContext(r.Context(), `
+		UPDATE service_versions SET deleted_at = CURRENT_TIMESTAMP, revision = revision + 1
+		WHERE id = ? AND service_id = ? AND deleted_at IS NULL`+condition)
 	if err != nil {
-		h.logger.Error("failed to prepare delete statement", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to prepare delete statement", zap.Error(err))
+		WriteError(w, r, errInternal)
 		return
 	}
 	defer stmt.Close()
 
 	// Execute the SQL statement to delete the version.
-	_, err = stmt.Exec(versionID, serviceID)
+	result, err := stmt.Exec(append([]interface{}{versionID, serviceID}, conditionValues...)...)
 	if err != nil {
-		h.logger.Error("failed to delete service version", zap.Error(err))
-		http.Error(w, `{"error": "Internal server error"}`, http.StatusInternalServerError)
+		h.requestLogger(r).Error("failed to delete service version", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Record the deletion, if the version was deleted.
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		after, err := loadServiceVersion(r.Context(), tx, serviceID, versionID)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, auditEntry{auditDelete, auditServiceVersion, versionID, before, after})
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit service version deletion", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+	}
+	if !h.checkPrecondition(w, r, precondition, result,
+		"SELECT 1 FROM service_versions WHERE id = ? AND service_id = ? AND deleted_at IS NULL", versionID, serviceID) {
 		return
 	}
 
@@ -0,0 +1,272 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// HistoryEntry is a revision of a service or of one of its versions, with the
+// fields it changed.
+type HistoryEntry struct {
+	// ResourceType is the type of the changed resource: service or
+	// service_version.
+	ResourceType string `json:"resource_type"`
+	// ResourceID identifies the changed resource at this revision.
+	ResourceID string `json:"resource_id"`
+	// Revision of the resource after the change.
+	Revision int64 `json:"revision"`
+	// Timestamp of the change.
+	ChangedAt time.Time `json:"changed_at"`
+	// Changes lists the fields changed by the revision. The first revision of
+	// a resource changes every field it sets.
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is the change of a single field by a revision.
+type FieldChange struct {
+	// Field is the name of the changed field.
+	Field string `json:"field"`
+	// From is the value before the change, null if the field was not set.
+	From interface{} `json:"from"`
+	// To is the value after the change, null if the field was cleared.
+	To interface{} `json:"to"`
+}
+
+// diffFields returns the fields of a revision, given as changes to their
+// values, that differ from the previous revision, which is nil for the first
+// one.
+func diffFields(previous, fields []FieldChange) []FieldChange {
+	changes := []FieldChange{}
+	for i, field := range fields {
+		var from interface{}
+		if previous != nil {
+			from = previous[i].To
+		}
+		if from != field.To {
+			changes = append(changes, FieldChange{Field: field.Field, From: from, To: field.To})
+		}
+	}
+	return changes
+}
+
+// historyValue returns the value of a nullable column, or nil.
+func historyValue(value sql.NullString) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.String
+}
+
+// revisionFields returns the fields of a revision of a service or of a service
+// version, as changes to their values, in the order they are diffed. The
+// deletion time is formatted by historyDeletedAt.
+func revisionFields(resourceType string, id string, name, description, version,
+	deletedAt sql.NullString) []FieldChange {
+	if resourceType == auditService {
+		return []FieldChange{{Field: "name", To: historyValue(name)},
+			{Field: "description", To: historyValue(description)}, {Field: "deleted_at", To: historyValue(deletedAt)}}
+	}
+	return []FieldChange{{Field: "id", To: id}, {Field: "version", To: historyValue(version)},
+		{Field: "deleted_at", To: historyValue(deletedAt)}}
+}
+
+// historyDeletedAt formats the deletion time of a revision as an RFC3339
+// time. Deletion times are written by SQLite with a precision of a second.
+const historyDeletedAt = "strftime('%Y-%m-%dT%H:%M:%SZ', deleted_at)"
+
+// historyRevisions selects the revisions of a service and of its versions,
+// along with the previous revision of the same resource, most recent first.
+// Only the revisions of the requested page are selected, but the previous ones
+// are looked up in the whole history.
+const historyRevisions = `SELECT resource_type, resource_id, revision, name, description, version, deleted_at,
+	valid_from, previous_seq, previous_id, previous_name, previous_description, previous_version,
+	previous_deleted_at
+	FROM (SELECT seq, resource_type, resource_id, revision, name, description, version,
+		` + historyDeletedAt + ` AS deleted_at, valid_from,
+		LAG(seq) OVER resource AS previous_seq,
+		LAG(resource_id) OVER resource AS previous_id,
+		LAG(name) OVER resource AS previous_name,
+		LAG(description) OVER resource AS previous_description,
+		LAG(version) OVER resource AS previous_version,
+		LAG(` + historyDeletedAt + `) OVER resource AS previous_deleted_at
+		FROM service_history WHERE service_id = ?
+		WINDOW resource AS (PARTITION BY resource_type, resource_rowid ORDER BY seq))
+	ORDER BY seq DESC LIMIT ? OFFSET ?`
+
+// ServiceHistoryHandler lists the revisions of a service and of its versions
+// one page at a time, most recent first, with the fields each one changed.
+// The history of a deleted service is kept after it is purged.
+func (h *Handler) ServiceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the service ID from the URL path variables.
+	vars := mux.Vars(r)
+	serviceID := vars["serviceId"]
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM service_history WHERE service_id = ?", serviceID).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count service history", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if total == 0 {
+		WriteError(w, r, errServiceNotFound)
+		return
+	}
+
+	rows, err := h.db.Query(historyRevisions, serviceID, limit, offset(page, limit))
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service history", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	// Diff every revision of the page against the previous one of the same
+	// resource.
+	items := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var name, description, version, deletedAt sql.NullString
+		var previousSeq sql.NullInt64
+		var previousID, previousName, previousDescription, previousVersion, previousDeletedAt sql.NullString
+		err := rows.Scan(&entry.ResourceType, &entry.ResourceID, &entry.Revision, &name, &description, &version,
+			&deletedAt, &entry.ChangedAt, &previousSeq, &previousID, &previousName, &previousDescription,
+			&previousVersion, &previousDeletedAt)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan service history", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		var previous []FieldChange
+		if previousSeq.Valid {
+			previous = revisionFields(entry.ResourceType, previousID.String, previousName, previousDescription,
+				previousVersion, previousDeletedAt)
+		}
+		fields := revisionFields(entry.ResourceType, entry.ResourceID, name, description, version, deletedAt)
+		entry.Changes = diffFields(previous, fields)
+		items = append(items, entry)
+	}
+	if err := rows.Err(); err != nil {
+		h.requestLogger(r).Error("failed to read service history", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Return the requested page, most recent revisions first.
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      items,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// RevertServiceHandler restores the name and description a service had at a
+// prior revision, as a new revision. Its versions are left unchanged, and a
+// deleted service has to be restored before it is reverted.
+func (h *Handler) RevertServiceHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the service ID and the revision from the URL path variables.
+	vars := mux.Vars(r)
+	serviceID := vars["serviceId"]
+	revision, err := strconv.ParseInt(vars["revision"], 10, 64)
+	if err != nil {
+		WriteError(w, r, errRevisionNotFound)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := loadService(r.Context(), tx, serviceID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	} else if before == nil || before.DeletedAt != nil {
+		WriteError(w, r, errServiceNotFound)
+		return
+	}
+
+	var name nullString
+	var description string
+	err = tx.QueryRowContext(r.Context(), `SELECT name, description FROM service_history
+		WHERE service_id = ? AND resource_type = 'service' AND revision = ?`, serviceID, revision).Scan(&name,
+		&description)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, r, errRevisionNotFound)
+		return
+	} else if err != nil {
+		h.requestLogger(r).Error("failed to query service revision", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Only revert the service if its revision matches the If-Match header.
+	precondition := parseIfMatch(r)
+	condition, conditionValues := precondition.clause()
+	result, err := tx.ExecContext(r.Context(), `UPDATE services
+		SET name = ?, description = ?, revision = revision + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL`+condition,
+		append([]interface{}{name, description, serviceID}, conditionValues...)...)
+	if err != nil {
+		h.requestLogger(r).Error("failed to revert service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if !h.checkPrecondition(w, r, precondition, result, "SELECT 1 FROM services WHERE id = ? AND deleted_at IS NULL",
+		serviceID) {
+		return
+	}
+
+	service, err := loadService(r.Context(), tx, serviceID)
+	if err == nil {
+		err = h.commitWithAudit(r, tx, auditEntry{auditRevert, auditService, serviceID, before, service})
+	}
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit service revert", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("service reverted", zap.String("service", serviceID), zap.Int64("revision", revision))
+
+	// Return a 200 OK response with the reverted service.
+	setETag(w, service.Revision)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": service})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
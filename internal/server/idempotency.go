@@ -0,0 +1,184 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key identifying the retries of a
+	// creation.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks the responses replayed to a retry.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotentResponse is the state of an idempotency key: the request it was
+// first used for, and its response once known.
+type idempotentResponse struct {
+	requestHash string
+	status      sql.NullInt64
+	header      sql.NullString
+	body        []byte
+}
+
+// Idempotent makes a creation safe to retry. The first response to a request
+// with an Idempotency-Key header is stored for the idempotency window, and
+// replayed to the retries of the same client with the same key instead of
+// creating another resource. Reusing a key for another request is rejected
+// with a 422, and retrying while the first request is in progress with a 409.
+// Server errors are not stored, so that the creation can be retried.
+func (h *Handler) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.requestLogger(r).Error("unable to read request body", zap.Error(err))
+			WriteError(w, r, errInvalidPayload)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		client, requestHash := h.clientKey(r), hashRequest(r, body)
+
+		stored, err := h.reserveIdempotencyKey(client, key, requestHash)
+		if err != nil {
+			h.requestLogger(r).Error("failed to reserve idempotency key", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.requestHash != requestHash:
+				h.requestLogger(r).Warn("idempotency key reused for another request", zap.String("key", key))
+				WriteError(w, r, errIdempotencyReuse)
+			case !stored.status.Valid:
+				WriteError(w, r, errIdempotencyBusy)
+			default:
+				h.requestLogger(r).Info("replaying response", zap.String("key", key))
+				h.replay(w, r, stored)
+			}
+			return
+		}
+
+		// Hold the response back to store it, releasing the key if no response
+		// is stored, e.g. on a server error, so that the creation can be retried.
+		rec := newResponseRecorder()
+		completed := false
+		defer func() {
+			if !completed {
+				if _, err := h.db.Exec("DELETE FROM idempotency_keys WHERE client = ? AND key = ?",
+					client, key); err != nil {
+					h.requestLogger(r).Error("failed to release idempotency key", zap.Error(err))
+				}
+			}
+		}()
+		next(rec, r)
+		if rec.status < http.StatusInternalServerError {
+			completed = h.storeIdempotentResponse(r, client, key, rec)
+		}
+		rec.writeTo(w)
+	}
+}
+
+// hashRequest identifies a request by its method, path and payload. JSON
+// payloads are compacted first, so that retries only differing in whitespace
+// are the same request.
+func hashRequest(r *http.Request, body []byte) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err == nil {
+		body = compacted.Bytes()
+	}
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// reserveIdempotencyKey reserves the key of a client for the request, after
+// dropping the expired keys. If the key is already in use, its state is
+// returned instead.
+func (h *Handler) reserveIdempotencyKey(client string, key string, requestHash string) (*idempotentResponse,
+	error) {
+	now := time.Now().UTC()
+	_, err := h.db.Exec("DELETE FROM idempotency_keys WHERE created_at < ?", now.Add(-h.idempotencyWindow))
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.db.Exec(`INSERT OR IGNORE INTO idempotency_keys (client, key, request_hash, created_at)
+		VALUES (?, ?, ?, ?)`, client, key, requestHash, now)
+	if err != nil {
+		return nil, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 1 {
+		return nil, err
+	}
+
+	var stored idempotentResponse
+	err = h.db.QueryRow("SELECT request_hash, status, header, body FROM idempotency_keys WHERE client = ? AND key = ?",
+		client, key).Scan(&stored.requestHash, &stored.status, &stored.header, &stored.body)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// storeIdempotentResponse stores the response to the request of a reserved
+// key, and reports whether it was stored.
+func (h *Handler) storeIdempotentResponse(r *http.Request, client string, key string, rec *responseRecorder) bool {
+	header, err := json.Marshal(rec.header)
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response headers", zap.Error(err))
+		return false
+	}
+	_, err = h.db.Exec("UPDATE idempotency_keys SET status = ?, header = ?, body = ? WHERE client = ? AND key = ?",
+		rec.status, string(header), rec.body.Bytes(), client, key)
+	if err != nil {
+		h.requestLogger(r).Error("failed to store idempotent response", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// replay writes a stored response again.
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request, stored *idempotentResponse) {
+	var header http.Header
+	if err := json.Unmarshal([]byte(stored.header.String), &header); err != nil {
+		h.requestLogger(r).Error("unable to decode stored response headers", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(int(stored.status.Int64))
+	if _, err := w.Write(stored.body); err != nil {
+		h.requestLogger(r).Error("unable to write response", zap.Error(err))
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+// Outcomes of a login attempt, as recorded in the login audit trail.
+const (
+	loginSucceeded          = "succeeded"
+	loginInvalidCredentials = "invalid_credentials"
+	loginLockedOut          = "locked_out"
+)
+
+// LoginAttempt is an entry of the login audit trail.
+type LoginAttempt struct {
+	// Unique identifier for the attempt.
+	ID string `json:"id"`
+	// Username the client tried to log in as.
+	Username string `json:"username"`
+	// IP address of the client.
+	IP string `json:"ip"`
+	// Outcome of the attempt: succeeded, invalid_credentials or locked_out.
+	Outcome string `json:"outcome"`
+	// RequestID identifies the request of the attempt.
+	RequestID string `json:"request_id"`
+	// Timestamp of the attempt.
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// loginAttemptColumns are the columns of the login audit trail.
+const loginAttemptColumns = "id, username, ip, outcome, request_id, attempted_at"
+
+// maxLoginUsernameLength is the length of the longest username, beyond which
+// the usernames of login attempts are truncated before they are counted,
+// logged and recorded.
+const maxLoginUsernameLength = 64
+
+// loginFailures counts the recent failed logins of a username or a client IP,
+// along with the attempts in progress.
+type loginFailures struct {
+	count       int
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter keeps the failed logins per username and per client IP in
+// memory. Once a key reaches its maximum number of failures, every further
+// failure locks it out for twice as long as the previous one. Attempts are
+// reserved before their password is checked, so that concurrent attempts
+// cannot exceed the maximum together.
+type loginLimiter struct {
+	cfg config.LoginProtection
+
+	mu        sync.Mutex
+	failures  map[string]*loginFailures
+	lastSweep time.Time
+}
+
+// newLoginLimiter creates a limiter for the given configuration.
+func newLoginLimiter(cfg config.LoginProtection) *loginLimiter {
+	return &loginLimiter{cfg: cfg, failures: map[string]*loginFailures{}, lastSweep: time.Now()}
+}
+
+// keys returns the counters of a login attempt along with their maximum
+// number of failures. Counters with a maximum of zero are disabled.
+func (l *loginLimiter) keys(username string, ip string) map[string]int {
+	keys := map[string]int{}
+	if l.cfg.MaxFailuresPerUsername > 0 {
+		keys["username:"+username] = l.cfg.MaxFailuresPerUsername
+	}
+	if l.cfg.MaxFailuresPerIP > 0 {
+		keys["ip:"+ip] = l.cfg.MaxFailuresPerIP
+	}
+	return keys
+}
+
+// reserve reserves a login attempt of the username from the client IP, which
+// must then be ended with recordFailure, recordSuccess or release. It returns
+// how long the username or the client IP remains locked out, or zero if the
+// attempt was reserved. Attempts are also rejected, for the base lockout,
+// while those in progress could reach the maximum number of failures, or
+// while one is in progress past the maximum.
+func (l *loginLimiter) reserve(username string, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	var wait time.Duration
+	for key, max := range l.keys(username, ip) {
+		f := l.counter(key, now)
+		if f.lockedUntil.After(now) && f.lockedUntil.Sub(now) > wait {
+			wait = f.lockedUntil.Sub(now)
+		} else if f.pending > 0 && f.count+f.pending >= max && l.cfg.BaseLockout > wait {
+			wait = l.cfg.BaseLockout
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for key := range l.keys(username, ip) {
+		l.failures[key].pending++
+	}
+	return 0
+}
+
+// recordFailure counts the reserved attempt of the username from the client
+// IP as a failed login.
+func (l *loginLimiter) recordFailure(username string, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for key, max := range l.keys(username, ip) {
+		f := l.counter(key, now)
+		f.pending--
+		f.count++
+		f.lastFailure = now
+		if f.count >= max {
+			f.lockedUntil = now.Add(l.lockout(f.count - max))
+		}
+	}
+}
+
+// recordSuccess ends the reserved attempt of the username from the client IP
+// and forgets the failed logins of the username. The failures of the client IP
+// are kept, so that a valid account cannot be used to reset them.
+func (l *loginLimiter) recordSuccess(username string, ip string) {
+	l.release(username, ip)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.failures["username:"+username]; ok {
+		f.count = 0
+		f.lockedUntil = time.Time{}
+	}
+}
+
+// release ends the reserved attempt of the username from the client IP
+// without counting it, when the password could not be checked.
+func (l *loginLimiter) release(username string, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.keys(username, ip) {
+		if f, ok := l.failures[key]; ok {
+			f.pending--
+		}
+	}
+}
+
+// counter returns the counter of a key, whose failures are forgotten once the
+// failure window has passed since the last one.
+func (l *loginLimiter) counter(key string, now time.Time) *loginFailures {
+	f, ok := l.failures[key]
+	if !ok {
+		f = &loginFailures{}
+		l.failures[key] = f
+	} else if now.Sub(f.lastFailure) > l.cfg.FailureWindow && now.After(f.lockedUntil) {
+		f.count = 0
+	}
+	return f
+}
+
+// lockout returns the duration of a lockout after the given number of failures
+// past the maximum: the base lockout, doubled for every failure, capped to the
+// maximum lockout.
+func (l *loginLimiter) lockout(extraFailures int) time.Duration {
+	lockout := l.cfg.BaseLockout
+	for i := 0; i < extraFailures && lockout < l.cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if l.cfg.MaxLockout > 0 && lockout > l.cfg.MaxLockout {
+		lockout = l.cfg.MaxLockout
+	}
+	return lockout
+}
+
+// sweep drops the counters that are neither recent nor locked out, at most
+// once per failure window, so that memory does not grow with every client.
+func (l *loginLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.cfg.FailureWindow {
+		return
+	}
+	l.lastSweep = now
+	for key, f := range l.failures {
+		if f.pending == 0 && now.Sub(f.lastFailure) > l.cfg.FailureWindow && now.After(f.lockedUntil) {
+			delete(l.failures, key)
+		}
+	}
+}
+
+// clientIP returns the IP address of the client of a request: the first
+// address of the X-Forwarded-For header when it is trusted, otherwise the
+// remote address of the connection.
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// loginUsername returns the username of a login attempt, truncated to the
+// length of the longest username, since clients can send any value.
+func loginUsername(username string) string {
+	if runes := []rune(username); len(runes) > maxLoginUsernameLength {
+		return string(runes[:maxLoginUsernameLength])
+	}
+	return username
+}
+
+// writeTooManyLogins rejects a login attempt of a locked out client, telling
+// it when to retry.
+func writeTooManyLogins(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	WriteError(w, r, errTooManyLogins)
+}
+
+// recordLoginAttempt adds a login attempt to the audit trail. Failing to
+// record it does not fail the login.
+func (h *Handler) recordLoginAttempt(r *http.Request, username string, ip string, outcome string) {
+	logger := h.requestLogger(r).With(zap.String("login_username", username), zap.String("ip", ip),
+		zap.String("outcome", outcome))
+	if outcome == loginSucceeded {
+		logger.Info("login attempt")
+	} else {
+		logger.Warn("login attempt")
+	}
+
+	_, err := h.db.Exec(`INSERT INTO login_attempts (id, username, ip, outcome, request_id, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), username, ip, outcome, RequestIDFromContext(r.Context()), time.Now().UTC())
+	if err != nil {
+		logger.Error("failed to record login attempt", zap.Error(err))
+	}
+}
+
+// ListLoginAttemptsHandler lists the login audit trail one page at a time,
+// most recent attempts first, optionally filtered by username.
+func (h *Handler) ListLoginAttemptsHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+	username := r.URL.Query().Get("username")
+
+	var total int
+	err = h.db.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE (? = '' OR username = ?)",
+		username, username).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count login attempts", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM login_attempts WHERE (? = '' OR username = ?)
+		ORDER BY attempted_at DESC, rowid DESC LIMIT ? OFFSET ?`, loginAttemptColumns)
+	rows, err := h.db.Query(query, username, username, limit, offset(page, limit))
+	if err != nil {
+		h.requestLogger(r).Error("failed to query login attempts", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	attempts := []LoginAttempt{}
+	for rows.Next() {
+		var a LoginAttempt
+		err := rows.Scan(&a.ID, &a.Username, &a.IP, &a.Outcome, &a.RequestID, &a.AttemptedAt)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan login attempt", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		attempts = append(attempts, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      attempts,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// counterVec is a counter partitioned by a set of labels.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// newCounterVec creates a counter with the given label names.
+func newCounterVec(name string, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: map[string]uint64{}}
+}
+
+// inc increments the counter of the given label values, in the order of the
+// label names.
+func (c *counterVec) inc(values ...string) {
+	pairs := make([]string, len(c.labels))
+	for i, label := range c.labels {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label, value)
+	}
+	key := strings.Join(pairs, ",")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key]++
+}
+
+// write writes the counter in the Prometheus text format.
+func (c *counterVec) write(b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s{%s} %d\n", c.name, key, c.values[key])
+	}
+}
+
+// metrics are the counters exposed by the server.
+type metrics struct {
+	throttledRequests *counterVec
+	injectedFaults    *counterVec
+}
+
+// newMetrics creates the counters exposed by the server.
+func newMetrics() *metrics {
+	return &metrics{
+		throttledRequests: newCounterVec("http_requests_throttled_total",
+			"Requests rejected by the rate limiter.", "route", "limit"),
+		injectedFaults: newCounterVec("http_faults_injected_total",
+			"Faults injected in requests for chaos testing.", "route", "fault"),
+	}
+}
+
+// MetricsHandler exposes the metrics of the server in the Prometheus text
+// format.
+func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	h.metrics.throttledRequests.write(&b)
+	h.metrics.injectedFaults.write(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		h.requestLogger(r).Error("unable to write metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultJWKSRefreshInterval is how long the keys of a trusted issuer are
+	// cached when its refresh interval is not configured.
+	defaultJWKSRefreshInterval = 10 * time.Minute
+	// jwksRefreshCooldown is the minimum time between two fetches of the keys
+	// of a trusted issuer, when a token is signed with an unknown key.
+	jwksRefreshCooldown = time.Second
+	// jwksFetchTimeout bounds the time spent fetching the keys of an issuer.
+	jwksFetchTimeout = 5 * time.Second
+	// externalUserSeparator separates the issuer from the username of the
+	// users of trusted issuers, so that they never match a local user.
+	externalUserSeparator = "|"
+)
+
+// verificationKey is a public key of a trusted issuer.
+type verificationKey struct {
+	alg    string
+	public crypto.PublicKey
+}
+
+// remoteKeySet caches the keys of a trusted issuer. The keys are fetched again
+// once they are older than the refresh interval, or when a token is signed
+// with an unknown key, so that the issuer can rotate its keys. Keys are
+// fetched without holding the lock, one fetch at a time.
+type remoteKeySet struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu         sync.Mutex
+	keys       map[string]verificationKey
+	fetchedAt  time.Time
+	fetchErr   error
+	refreshing chan struct{}
+}
+
+// trustedIssuer is an external identity provider whose tokens are accepted.
+type trustedIssuer struct {
+	issuer        string
+	audience      string
+	usernameClaim string
+	rolesClaim    string
+	roleMapping   map[string]string
+	keys          *remoteKeySet
+}
+
+// newTrustedIssuers builds the trusted issuers from the configuration, loading
+// the static key sets. Remote key sets are fetched when first needed.
+func newTrustedIssuers(issuers []config.TrustedIssuer, localIssuer string) (map[string]*trustedIssuer, error) {
+	trusted := map[string]*trustedIssuer{}
+	for _, c := range issuers {
+		switch {
+		case c.Issuer == "":
+			return nil, errors.New("every trusted issuer must have an issuer")
+		case c.Issuer == localIssuer:
+			return nil, fmt.Errorf("trusted issuer %q is the issuer of the server", c.Issuer)
+		case trusted[c.Issuer] != nil:
+			return nil, fmt.Errorf("duplicate trusted issuer %q", c.Issuer)
+		case (c.JWKSURL == "") == (c.JWKSFile == ""):
+			return nil, fmt.Errorf("trusted issuer %q must have either a jwks_url or a jwks_file", c.Issuer)
+		case c.Audience == "":
+			return nil, fmt.Errorf("trusted issuer %q must have an audience", c.Issuer)
+		}
+
+		issuer := &trustedIssuer{
+			issuer:        c.Issuer,
+			audience:      c.Audience,
+			usernameClaim: c.UsernameClaim,
+			rolesClaim:    c.RolesClaim,
+			roleMapping:   map[string]string{},
+			keys: &remoteKeySet{
+				url:      c.JWKSURL,
+				interval: c.JWKSRefreshInterval,
+				client:   &http.Client{Timeout: jwksFetchTimeout},
+			},
+		}
+		if issuer.usernameClaim == "" {
+			issuer.usernameClaim = "sub"
+		}
+		if issuer.keys.interval <= 0 {
+			issuer.keys.interval = defaultJWKSRefreshInterval
+		}
+		for value, role := range c.RoleMapping {
+			switch role {
+			case RoleViewer, RoleEditor, RoleAdmin:
+			default:
+				return nil, fmt.Errorf("unknown role %q mapped by trusted issuer %q", role, c.Issuer)
+			}
+			issuer.roleMapping[strings.ToLower(value)] = role
+		}
+
+		if c.JWKSFile != "" {
+			data, err := os.ReadFile(c.JWKSFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read JWKS of trusted issuer %q: %w", c.Issuer, err)
+			}
+			if issuer.keys.keys, err = parseJWKS(data); err != nil {
+				return nil, fmt.Errorf("invalid JWKS of trusted issuer %q: %w", c.Issuer, err)
+			}
+		}
+		trusted[c.Issuer] = issuer
+	}
+	return trusted, nil
+}
+
+// parseJWKS decodes the RSA and EC keys of a JSON Web Key Set, skipping the
+// keys of other types.
+func parseJWKS(data []byte) (map[string]verificationKey, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("unable to decode JWKS: %w", err)
+	}
+	keys := map[string]verificationKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = *key
+		}
+	}
+	return keys, nil
+}
+
+// verificationKey decodes a public RSA or EC key. It returns nil for keys of
+// other types.
+func (k JWK) verificationKey() (*verificationKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("invalid base64url value")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		alg := k.Alg
+		if alg == "" {
+			alg = jwt.SigningMethodRS256.Alg()
+		}
+		return &verificationKey{alg: alg, public: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if k.Crv != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		alg := k.Alg
+		if alg == "" {
+			alg = jwt.SigningMethodES256.Alg()
+		}
+		return &verificationKey{alg: alg, public: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}, nil
+	}
+	return nil, nil
+}
+
+// key returns the key with the given kid. Stale keys are served while they
+// are fetched again in the background; an unknown kid waits for the keys to be
+// fetched again.
+func (s *remoteKeySet) key(kid string) (verificationKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	if s.url != "" {
+		age := time.Since(s.fetchedAt)
+		switch {
+		case ok && age > s.interval:
+			s.refresh()
+		case !ok && age > jwksRefreshCooldown:
+			done := s.refresh()
+			s.mu.Unlock()
+			<-done
+			s.mu.Lock()
+			key, ok = s.keys[kid]
+		}
+	}
+	// Stale keys are kept when the issuer cannot be reached.
+	var err error
+	if s.keys == nil {
+		err = s.fetchErr
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		if err != nil {
+			return verificationKey{}, err
+		}
+		return verificationKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// refresh starts fetching the keys from the JWKS URL of the issuer, unless
+// they are already being fetched, and returns a channel closed once they are.
+// It is called with s.mu held.
+func (s *remoteKeySet) refresh() <-chan struct{} {
+	if s.refreshing != nil {
+		return s.refreshing
+	}
+	done := make(chan struct{})
+	s.refreshing = done
+	s.fetchedAt = time.Now()
+	go func() {
+		keys, err := s.fetch()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err == nil {
+			s.keys = keys
+		}
+		s.fetchErr = err
+		s.refreshing = nil
+		close(done)
+	}()
+	return done
+}
+
+// fetch fetches the keys from the JWKS URL of the issuer.
+func (s *remoteKeySet) fetch() (map[string]verificationKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var data json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("unable to decode JWKS: %w", err)
+	}
+	return parseJWKS(data)
+}
+
+// keyFunc returns the key of the issuer identified by the kid header of a
+// token, rejecting tokens signed with another algorithm than the one of the
+// key.
+func (i *trustedIssuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := i.keys.key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// roles maps the values of the roles claim to the roles of the catalog.
+func (i *trustedIssuer) roles(claims jwt.MapClaims) []string {
+	var values []string
+	switch claim := claims[i.rolesClaim].(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		role, ok := i.roleMapping[strings.ToLower(value)]
+		if ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// trustedIssuerOf returns the trusted issuer of a token, if any. The token is
+// not verified.
+func (h *Handler) trustedIssuerOf(tokenStr string) *trustedIssuer {
+	if len(h.trustedIssuers) == 0 {
+		return nil
+	}
+	var claims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenStr, &claims); err != nil {
+		return nil
+	}
+	return h.trustedIssuers[claims.Issuer]
+}
+
+// authenticateExternalToken authenticates a request using a token issued by a
+// trusted identity provider. Its users are not known to the server: their
+// roles are mapped from the claims of the token, and their username is
+// prefixed with the issuer, as issuer|username, so that they never own the
+// API keys or the tokens of a local user of the same name.
+func (h *Handler) authenticateExternalToken(r *http.Request, issuer *trustedIssuer, tokenStr string) (*Claims,
+	error) {
+	logger := h.requestLogger(r).With(zap.String("issuer", issuer.issuer))
+
+	var registered jwt.RegisteredClaims
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenStr, &registered, issuer.keyFunc)
+	if err != nil || !token.Valid {
+		logger.Warn("invalid external token", zap.Error(err))
+		return nil, errInvalidToken
+	}
+	if err := h.validateClaims(&registered, issuer.issuer, issuer.audience, false); err != nil {
+		logger.Warn("external token claims rejected", zap.String("code", err.Code),
+			zap.String("subject", registered.Subject))
+		return nil, err
+	}
+
+	// The signature was verified above, the other claims are read as is.
+	claims := jwt.MapClaims{}
+	if _, _, err := parser.ParseUnverified(tokenStr, claims); err != nil {
+		return nil, errInvalidToken
+	}
+	username, _ := claims[issuer.usernameClaim].(string)
+	if username == "" {
+		logger.Warn("external token without username", zap.String("claim", issuer.usernameClaim))
+		return nil, errInvalidSubject
+	}
+
+	revoked, err := h.isRevoked(registered.ID)
+	if err != nil || revoked {
+		logger.Warn("revoked token", zap.String("jti", registered.ID), zap.Error(err))
+		return nil, errors.New("revoked token")
+	}
+
+	return &Claims{
+		TokenID:   registered.ID,
+		Issuer:    issuer.issuer,
+		Username:  issuer.issuer + externalUserSeparator + username,
+		ExpiresAt: registered.ExpiresAt.Time,
+		Roles:     issuer.roles(claims),
+	}, nil
+}
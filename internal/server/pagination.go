@@ -0,0 +1,132 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPage  = 1
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// Pagination describes the page of results returned by a list endpoint.
+type Pagination struct {
+	// Page is the current page number, starting at 1.
+	Page int `json:"page"`
+	// Limit is the maximum number of results per page.
+	Limit int `json:"limit"`
+	// Total is the total number of results across all pages.
+	Total int `json:"total"`
+	// TotalPages is the number of pages available for the given limit.
+	TotalPages int `json:"total_pages"`
+	// NextPage is the next page number, or null on the last page.
+	NextPage *int `json:"next_page"`
+	// PrevPage is the previous page number, or null on the first page.
+	PrevPage *int `json:"prev_page"`
+}
+
+// parsePagination reads the page and limit query parameters from the request,
+// applying defaults when they are absent.
+func parsePagination(r *http.Request) (page int, limit int, err error) {
+	page, err = parsePositiveInt(r.URL.Query().Get("page"), defaultPage)
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, err = parsePositiveInt(r.URL.Query().Get("limit"), defaultLimit)
+	if err != nil || limit > maxLimit {
+		return 0, 0, errInvalidPagination
+	}
+	return page, limit, nil
+}
+
+// parsePositiveInt parses a strictly positive integer, returning def if s is empty.
+func parsePositiveInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, errInvalidPagination
+	}
+	return n, nil
+}
+
+// parseSort reads the sort and order query parameters from the request. The
+// sort parameter must be one of the keys of columns, which maps the public
+// field name to its database column; it falls back to def when absent.
+func parseSort(r *http.Request, columns map[string]string, def string) (column string, direction string, err error) {
+	field := r.URL.Query().Get("sort")
+	if field == "" {
+		field = def
+	}
+	column, ok := columns[field]
+	if !ok {
+		return "", "", errInvalidSort
+	}
+
+	switch strings.ToLower(r.URL.Query().Get("order")) {
+	case "", "asc":
+		direction = "ASC"
+	case "desc":
+		direction = "DESC"
+	default:
+		return "", "", errInvalidSort
+	}
+	return column, direction, nil
+}
+
+// includeDeleted reports whether the include_deleted query parameter asks for
+// the deleted resources to be listed along with the others.
+func includeDeleted(r *http.Request) bool {
+	include, err := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	return err == nil && include
+}
+
+// escapeLike escapes the LIKE wildcards in s so it can be matched literally
+// using ESCAPE '\'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// newPagination builds the pagination metadata for a page of results.
+func newPagination(page int, limit int, total int) Pagination {
+	totalPages := (total + limit - 1) / limit
+	p := Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+	if page < totalPages {
+		next := page + 1
+		p.NextPage = &next
+	}
+	if page > 1 {
+		prev := min(page-1, max(totalPages, 1))
+		p.PrevPage = &prev
+	}
+	return p
+}
+
+// offset returns the number of rows to skip to reach the given page.
+func offset(page int, limit int) int {
+	return (page - 1) * limit
+}
@@ -0,0 +1,277 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+// bucketSweepInterval is how often the buckets that are full again are
+// dropped, so that memory does not grow with every client.
+const bucketSweepInterval = time.Minute
+
+// tokenBucket holds the tokens left to a client at the time of its last
+// request.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// bucketLimiter is a token bucket rate limiter with a bucket for every key.
+type bucketLimiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// rateLimitResult describes the bucket of a request after taking a token.
+type rateLimitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// newBucketLimiter creates a limiter for the given limit, or returns nil if the
+// limit is disabled.
+func newBucketLimiter(rate float64, burst int) (*bucketLimiter, error) {
+	switch {
+	case rate == 0:
+		return nil, nil
+	case rate < 0:
+		return nil, fmt.Errorf("invalid rate limit of %v requests per second", rate)
+	case burst < 1:
+		return nil, fmt.Errorf("invalid rate limit burst of %d requests", burst)
+	}
+	return &bucketLimiter{rate: rate, burst: burst, buckets: map[string]*tokenBucket{}, lastSweep: time.Now()}, nil
+}
+
+// take takes a token from the bucket of the key, if one is left.
+func (l *bucketLimiter) take(key string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	result := rateLimitResult{limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = l.refillTime(1 - b.tokens)
+	}
+	result.remaining = int(b.tokens)
+	result.reset = l.refillTime(float64(l.burst) - b.tokens)
+	return result
+}
+
+// refillTime returns how long it takes to refill the given number of tokens.
+func (l *bucketLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops the buckets that are full again, as they behave like new ones.
+func (l *bucketLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.refillTime(float64(l.burst)-b.tokens) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimiter applies the global, per-client, per-IP and per-route rate
+// limits.
+type rateLimiter struct {
+	global    *bucketLimiter
+	perClient *bucketLimiter
+	perIP     *bucketLimiter
+	routes    map[string]*bucketLimiter
+}
+
+// newRateLimiter creates the rate limiters from the configuration.
+func newRateLimiter(cfg config.RateLimiting) (*rateLimiter, error) {
+	global, err := newBucketLimiter(cfg.Global.RequestsPerSecond, cfg.Global.Burst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid global rate limit: %w", err)
+	}
+	perClient, err := newBucketLimiter(cfg.PerClient.RequestsPerSecond, cfg.PerClient.Burst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid per client rate limit: %w", err)
+	}
+	perIP, err := newBucketLimiter(cfg.PerIP.RequestsPerSecond, cfg.PerIP.Burst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid per IP rate limit: %w", err)
+	}
+	limiter := &rateLimiter{global: global, perClient: perClient, perIP: perIP, routes: map[string]*bucketLimiter{}}
+	for _, route := range cfg.Routes {
+		if route.Method == "" || route.Path == "" {
+			return nil, fmt.Errorf("every rate limited route must have a method and a path")
+		}
+		name := routeName(route.Method, route.Path)
+		if _, ok := limiter.routes[name]; ok {
+			return nil, fmt.Errorf("duplicate rate limit for route %s", name)
+		}
+		if limiter.routes[name], err = newBucketLimiter(route.RequestsPerSecond, route.Burst); err != nil {
+			return nil, fmt.Errorf("invalid rate limit for route %s: %w", name, err)
+		}
+	}
+	return limiter, nil
+}
+
+// routeName identifies a route by its method and path template.
+func routeName(method string, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// routeTemplate returns the path template of the route of a request, or its
+// path when no route matched.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// clientKey identifies the client of a request: the authenticated user, or
+// the client IP of anonymous requests.
+func (h *Handler) clientKey(r *http.Request) string {
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		return "user:" + claims.Username
+	}
+	return "ip:" + h.clientIP(r)
+}
+
+// IPRateLimitMiddleware limits the rate of the requests of every client IP,
+// except for the exempt routes. It runs before the authentication, so that
+// clients sending invalid credentials are limited as well.
+func (h *Handler) IPRateLimitMiddleware(exemptRoutes ...string) func(http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(exemptRoutes))
+	for _, route := range exemptRoutes {
+		exempt[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			template := routeTemplate(r)
+			if exempt[template] || h.rateLimiter.perIP == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if h.takeToken(w, r, h.rateLimiter.perIP, h.clientIP(r), routeName(r.Method, template), "ip") {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// RateLimitMiddleware limits the rate of the requests of every client, and of
+// all the clients together, except for the exempt routes. It runs after the
+// authentication, so that authenticated users are limited wherever they send
+// their requests from. The state of the bucket of the client is reported with
+// the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+func (h *Handler) RateLimitMiddleware(exemptRoutes ...string) func(http.Handler) http.Handler {
+	exempt := make(map[string]bool, len(exemptRoutes))
+	for _, route := range exemptRoutes {
+		exempt[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			template := routeTemplate(r)
+			if exempt[template] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Routes with their own limit have their own bucket for every client.
+			route := routeName(r.Method, template)
+			limiter, key := h.rateLimiter.perClient, h.clientKey(r)
+			if routeLimiter, ok := h.rateLimiter.routes[route]; ok {
+				limiter, key = routeLimiter, route+" "+key
+			}
+
+			if limiter != nil && !h.takeToken(w, r, limiter, key, route, "client") {
+				return
+			}
+			if h.rateLimiter.global != nil {
+				if result := h.rateLimiter.global.take(""); !result.allowed {
+					h.throttle(w, r, route, "global", result.retryAfter)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// takeToken takes a token from the bucket of the key, reporting the state of
+// the bucket in the RateLimit headers, and throttles the request if none is
+// left. It reports whether the request is allowed.
+func (h *Handler) takeToken(w http.ResponseWriter, r *http.Request, limiter *bucketLimiter, key string,
+	route string, limit string) bool {
+	result := limiter.take(key)
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.reset.Seconds()))))
+	if !result.allowed {
+		h.throttle(w, r, route, limit, result.retryAfter)
+	}
+	return result.allowed
+}
+
+// throttle rejects a request over a rate limit, telling the client when to
+// retry.
+func (h *Handler) throttle(w http.ResponseWriter, r *http.Request, route string, limit string,
+	retryAfter time.Duration) {
+	h.requestLogger(r).Warn("request throttled", zap.String("route", route), zap.String("limit", limit),
+		zap.Duration("retry_after", retryAfter))
+	h.metrics.throttledRequests.inc(route, limit)
+
+	setRetryAfter(w, retryAfter)
+	WriteError(w, r, errTooManyRequests)
+}
+
+// setRetryAfter sets the Retry-After header to the given delay, rounded up to
+// the second.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
@@ -0,0 +1,102 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Roles that can be granted to a user.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Permissions required by the routes, granted to the roles by the configuration.
+const (
+	// PermissionCatalogRead allows reading and searching services and versions.
+	PermissionCatalogRead = "catalog:read"
+	// PermissionCatalogWrite allows creating, updating and deleting services and versions.
+	PermissionCatalogWrite = "catalog:write"
+	// PermissionUsersManage allows creating, listing and updating users.
+	PermissionUsersManage = "users:manage"
+	// PermissionFaultsManage allows changing the faults injected in requests.
+	PermissionFaultsManage = "faults:manage"
+	// PermissionAuditRead allows reading the audit log.
+	PermissionAuditRead = "audit:read"
+)
+
+// rolePermissions holds the permissions granted to each role.
+type rolePermissions map[string]map[string]bool
+
+// newRolePermissions builds the permissions granted to each role from the
+// configured mapping, rejecting unknown roles and permissions.
+func newRolePermissions(roles map[string][]string) (rolePermissions, error) {
+	known := map[string]bool{
+		PermissionCatalogRead:  true,
+		PermissionCatalogWrite: true,
+		PermissionUsersManage:  true,
+		PermissionFaultsManage: true,
+		PermissionAuditRead:    true,
+	}
+	permissions := rolePermissions{}
+	for role, granted := range roles {
+		switch role {
+		case RoleViewer, RoleEditor, RoleAdmin:
+		default:
+			return nil, fmt.Errorf("unknown role %q in role mapping", role)
+		}
+		permissions[role] = map[string]bool{}
+		for _, permission := range granted {
+			if !known[permission] {
+				return nil, fmt.Errorf("unknown permission %q granted to role %q", permission, role)
+			}
+			permissions[role][permission] = true
+		}
+	}
+	return permissions, nil
+}
+
+// allows reports whether any of the roles grants the permission.
+func (p rolePermissions) allows(roles []string, permission string) bool {
+	for _, role := range roles {
+		if p[role][permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize only lets authenticated callers whose roles grant the permission
+// through to next, responding with a 403 otherwise.
+func (h *Handler) Authorize(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			WriteError(w, r, errUnauthorized)
+			return
+		}
+		if !h.permissions.allows(claims.Roles, permission) {
+			h.requestLogger(r).Warn("permission denied",
+				zap.Strings("roles", claims.Roles), zap.String("permission", permission))
+			WriteError(w, r, errForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
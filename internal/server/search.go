@@ -0,0 +1,166 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+const (
+	searchTypeService        = "service"
+	searchTypeServiceVersion = "service_version"
+
+	// Markers wrapped around the matched terms in search highlights.
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// SearchResult is a single match returned by the search endpoint.
+type SearchResult struct {
+	// Type of the matched resource, either "service" or "service_version".
+	Type string `json:"type"`
+	// Unique identifier of the matched resource.
+	ID string `json:"id"`
+	// ID of the service the matched resource belongs to.
+	ServiceID string `json:"service_id"`
+	// Name of the service the matched resource belongs to.
+	Name nullString `json:"name"`
+	// Version information, only set for service versions.
+	Version nullString `json:"version,omitempty"`
+	// Relevance score of the match; lower is more relevant.
+	Score float64 `json:"score"`
+	// Highlights holds the matched fields with the matching terms marked.
+	Highlights map[string]string `json:"highlights"`
+}
+
+// ftsQuery converts free text typed by a user into an FTS5 query. Every term
+// must match and is treated as a prefix so partial words such as "notif" still
+// find "Notifications". FTS5 operators in the input are matched literally.
+func ftsQuery(q string) (string, error) {
+	terms := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(terms) == 0 {
+		return "", errInvalidSearchQuery
+	}
+	for i, term := range terms {
+		terms[i] = `"` + term + `"*`
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// SearchHandler searches services and their versions in the catalog.
+// It matches the q query parameter against service names, descriptions and
+// version strings and returns the matches ordered by relevance.
+func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	match, err := ftsQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		h.requestLogger(r).Warn("invalid search query", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	// Count the matches across both indexes so clients can tell how many pages
+	// there are. Deleted services and versions, and the versions of deleted
+	// services, are not searched.
+	var total int
+	err = h.db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM services_fts JOIN services s ON s.rowid = services_fts.rowid
+			WHERE services_fts MATCH ? AND s.deleted_at IS NULL) +
+		(SELECT COUNT(*) FROM service_versions_fts JOIN service_versions v ON v.rowid = service_versions_fts.rowid
+			LEFT JOIN services s ON s.id = v.service_id
+			WHERE service_versions_fts MATCH ? AND v.deleted_at IS NULL AND s.deleted_at IS NULL)`,
+		match, match).Scan(&total)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count search results", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	// Query both indexes, ranking service names above descriptions, and merge
+	// the matches by relevance with the ID as a tie-breaker to keep pages stable.
+	rows, err := h.db.Query(`
+		SELECT ?, s.id, s.id, s.name, NULL, bm25(services_fts, 10.0, 1.0) AS score,
+			highlight(services_fts, 0, ?, ?), snippet(services_fts, 1, ?, ?, '…', 16)
+		FROM services_fts JOIN services s ON s.rowid = services_fts.rowid
+		WHERE services_fts MATCH ? AND s.deleted_at IS NULL
+		UNION ALL
+		SELECT ?, v.id, v.service_id, s.name, v.version, bm25(service_versions_fts) AS score,
+			highlight(service_versions_fts, 0, ?, ?), NULL
+		FROM service_versions_fts JOIN service_versions v ON v.rowid = service_versions_fts.rowid
+			LEFT JOIN services s ON s.id = v.service_id
+		WHERE service_versions_fts MATCH ? AND v.deleted_at IS NULL AND s.deleted_at IS NULL
+		ORDER BY score, 2 LIMIT ? OFFSET ?`,
+		searchTypeService, highlightStart, highlightEnd, highlightStart, highlightEnd, match,
+		searchTypeServiceVersion, highlightStart, highlightEnd, match,
+		limit, offset(page, limit))
+	if err != nil {
+		h.requestLogger(r).Error("failed to search catalog", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	// Iterate over the rows and build the list of matches.
+	var results []SearchResult
+	for rows.Next() {
+		var res SearchResult
+		var first, second nullString
+		err = rows.Scan(&res.Type, &res.ID, &res.ServiceID, &res.Name, &res.Version, &res.Score, &first, &second)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan search result", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		res.Highlights = searchHighlights(res.Type, first, second)
+		results = append(results, res)
+	}
+
+	// Return the page of matches along with the pagination metadata.
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      results,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// searchHighlights maps the highlighted columns of a search result to the
+// fields they belong to, dropping the fields that did not match.
+func searchHighlights(resultType string, first nullString, second nullString) map[string]string {
+	highlights := map[string]string{}
+	fields := []string{"name", "description"}
+	if resultType == searchTypeServiceVersion {
+		fields = []string{"version"}
+	}
+	for i, value := range []nullString{first, second}[:len(fields)] {
+		if value.Valid && strings.Contains(value.String, highlightStart) {
+			highlights[fields[i]] = value.String
+		}
+	}
+	return highlights
+}
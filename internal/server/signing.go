@@ -0,0 +1,226 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/config"
+	"go.uber.org/zap"
+)
+
+// signingKey is a key used to sign or verify JWT tokens.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// keySet holds the keys trusted to verify JWT tokens, one of which signs new
+// tokens. Several keys can be trusted at once so that keys can be rotated
+// without invalidating the tokens signed by the previous key.
+type keySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+	// order lists the kids in the order of the configuration, for the JWKS.
+	order []string
+}
+
+// JWKS is a JSON Web Key Set, as defined by RFC 7517.
+type JWKS struct {
+	// Keys are the public keys trusted to verify tokens.
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public RSA or EC JSON Web Key, as defined by RFC 7517 and RFC 7518.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X and Y are the curve and coordinates of EC keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// newKeySet loads the configured keys from their PEM files. It returns nil
+// when no keys are configured, in which case tokens are signed with the shared
+// secret instead.
+func newKeySet(keys []config.SigningKey, signingKID string) (*keySet, error) {
+	if len(keys) == 0 {
+		if signingKID != "" {
+			return nil, fmt.Errorf("signing key %q is not configured", signingKID)
+		}
+		return nil, nil
+	}
+
+	set := &keySet{keys: map[string]*signingKey{}}
+	for _, k := range keys {
+		if k.KID == "" {
+			return nil, errors.New("every signing key must have a kid")
+		}
+		if _, ok := set.keys[k.KID]; ok {
+			return nil, fmt.Errorf("duplicate signing key %q", k.KID)
+		}
+		key, err := loadSigningKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load signing key %q: %w", k.KID, err)
+		}
+		set.keys[k.KID] = key
+		set.order = append(set.order, k.KID)
+	}
+
+	set.signing = set.keys[signingKID]
+	if set.signing == nil {
+		return nil, fmt.Errorf("signing key %q is not configured", signingKID)
+	}
+	if set.signing.private == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKID)
+	}
+	return set, nil
+}
+
+// loadSigningKey loads a key from its PEM files, checking that it matches its
+// algorithm.
+func loadSigningKey(k config.SigningKey) (*signingKey, error) {
+	if k.PrivateKeyFile == "" && k.PublicKeyFile == "" {
+		return nil, errors.New("either a private or a public key file is required")
+	}
+	key := &signingKey{kid: k.KID}
+
+	var privatePEM, publicPEM []byte
+	var err error
+	if k.PrivateKeyFile != "" {
+		if privatePEM, err = os.ReadFile(k.PrivateKeyFile); err != nil {
+			return nil, fmt.Errorf("unable to read private key: %w", err)
+		}
+	}
+	if k.PublicKeyFile != "" {
+		if publicPEM, err = os.ReadFile(k.PublicKeyFile); err != nil {
+			return nil, fmt.Errorf("unable to read public key: %w", err)
+		}
+	}
+
+	switch k.Algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		key.method = jwt.SigningMethodRS256
+		if privatePEM != nil {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA private key: %w", err)
+			}
+			key.private, key.public = private, &private.PublicKey
+		}
+		if publicPEM != nil {
+			if key.public, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, fmt.Errorf("invalid RSA public key: %w", err)
+			}
+		}
+	case jwt.SigningMethodES256.Alg():
+		key.method = jwt.SigningMethodES256
+		if privatePEM != nil {
+			private, err := jwt.ParseECPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC private key: %w", err)
+			}
+			key.private, key.public = private, &private.PublicKey
+		}
+		if publicPEM != nil {
+			if key.public, err = jwt.ParseECPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, fmt.Errorf("invalid EC public key: %w", err)
+			}
+		}
+		if key.public.(*ecdsa.PublicKey).Curve != elliptic.P256() {
+			return nil, errors.New("ES256 keys must use the P-256 curve")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, expected RS256 or ES256", k.Algorithm)
+	}
+	return key, nil
+}
+
+// keyFunc returns the public key identified by the kid header of a token,
+// rejecting tokens signed with another algorithm than the one of the key.
+func (s *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// sign signs the claims with the signing key, identified by the kid header.
+func (s *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.kid
+	return token.SignedString(s.signing.private)
+}
+
+// jwks returns the public keys of the set as a JSON Web Key Set.
+func (s *keySet) jwks() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if s == nil {
+		return jwks
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	for _, kid := range s.order {
+		key := s.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encode(public.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = encode(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = encode(public.Y.FillBytes(make([]byte, size)))
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// JWKSHandler publishes the public keys trusted to verify the tokens issued by
+// the server, so that other services can verify them without a shared secret.
+func (h *Handler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	err := json.NewEncoder(w).Encode(h.keys.jwks())
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
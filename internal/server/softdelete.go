@@ -0,0 +1,269 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// RestoreServiceHandler restores a deleted service, until it is purged, along
+// with the versions deleted with it. Restoring a service that is not deleted
+// leaves it unchanged.
+func (h *Handler) RestoreServiceHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the service ID from the URL path variables.
+	vars := mux.Vars(r)
+	serviceID := vars["serviceId"]
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	service, err := loadService(r.Context(), tx, serviceID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	} else if service == nil {
+		WriteError(w, r, errServiceNotFound)
+		return
+	}
+
+	if service.DeletedAt != nil {
+		// Restore the versions deleted along with the service, but not the ones
+		// deleted on their own.
+		_, err = tx.ExecContext(r.Context(), `UPDATE service_versions
+			SET deleted_at = NULL, deleted_with_service = 0, revision = revision + 1
+			WHERE service_id = ? AND deleted_with_service`, serviceID)
+		if err != nil {
+			h.requestLogger(r).Error("failed to restore service versions", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		_, err = tx.ExecContext(r.Context(),
+			"UPDATE services SET deleted_at = NULL, revision = revision + 1 WHERE id = ?", serviceID)
+		if err != nil {
+			h.requestLogger(r).Error("failed to restore service", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+
+		before := service
+		service, err = loadService(r.Context(), tx, serviceID)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, auditEntry{auditRestore, auditService, serviceID, before, service})
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit service restoration", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+	}
+
+	// Return a 200 OK response with the restored service.
+	setETag(w, service.Revision)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": service})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// RestoreServiceVersionHandler restores a deleted version of a service, until
+// it is purged. Restoring a version that is not deleted leaves it unchanged.
+// The versions of a deleted service are restored along with the service.
+func (h *Handler) RestoreServiceVersionHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the service ID and version ID from the URL path variables.
+	vars := mux.Vars(r)
+	serviceID := vars["serviceId"]
+	versionID := vars["versionId"]
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	version, err := loadServiceVersion(r.Context(), tx, serviceID, versionID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to query service version", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	} else if version == nil {
+		WriteError(w, r, errVersionNotFound)
+		return
+	}
+
+	if version.DeletedAt != nil {
+		// Only restore the version if its service is not deleted.
+		result, err := tx.ExecContext(r.Context(), `UPDATE service_versions SET deleted_at = NULL, revision = revision + 1
+			WHERE id = ? AND service_id = ? AND EXISTS (SELECT 1 FROM services WHERE id = ? AND deleted_at IS NULL)`,
+			versionID, serviceID, serviceID)
+		if err != nil {
+			h.requestLogger(r).Error("failed to restore service version", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		if restored, _ := result.RowsAffected(); restored == 0 {
+			WriteError(w, r, errServiceNotFound)
+			return
+		}
+
+		before := version
+		version, err = loadServiceVersion(r.Context(), tx, serviceID, versionID)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, auditEntry{auditRestore, auditServiceVersion, versionID, before, version})
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit service version restoration", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+	}
+
+	// Return a 200 OK response with the restored version.
+	setETag(w, version.Revision)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": version})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// PurgeDeleted permanently removes the services and service versions deleted
+// for longer than the purge horizon, every purge interval until the context is
+// done. Nothing is purged when either is zero.
+func (h *Handler) PurgeDeleted(ctx context.Context) {
+	if h.purgeAfter <= 0 || h.purgeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.purgeDeleted(ctx); err != nil {
+				h.logger.Error("failed to purge deleted services", zap.Error(err))
+			}
+		}
+	}
+}
+
+// purgeDeleted removes the services and service versions deleted before the
+// purge horizon, along with the versions of the removed services, recording
+// them in the audit log as purged by the system.
+func (h *Handler) purgeDeleted(ctx context.Context) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The deletion times are written by SQLite, so the horizon is computed by
+	// SQLite as well to compare them in the same format, once so that the
+	// recorded and removed resources are the same.
+	var horizon string
+	err = tx.QueryRowContext(ctx, "SELECT datetime('now', ?)",
+		fmt.Sprintf("-%d seconds", int64(h.purgeAfter/time.Second))).Scan(&horizon)
+	if err != nil {
+		return err
+	}
+	entries, err := purgedEntries(ctx, tx, horizon)
+	if err != nil {
+		return err
+	}
+
+	versions, err := tx.ExecContext(ctx, `DELETE FROM service_versions WHERE deleted_at < ?
+		OR service_id IN (SELECT id FROM services WHERE deleted_at < ?)`, horizon, horizon)
+	if err != nil {
+		return err
+	}
+	services, err := tx.ExecContext(ctx, "DELETE FROM services WHERE deleted_at < ?", horizon)
+	if err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, tx, systemActor, uuid.NewString(), entries); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	purgedVersions, _ := versions.RowsAffected()
+	purgedServices, _ := services.RowsAffected()
+	if purgedVersions > 0 || purgedServices > 0 {
+		h.logger.Info("purged deleted services", zap.Int64("services", purgedServices),
+			zap.Int64("service_versions", purgedVersions))
+	}
+	return nil
+}
+
+// purgedEntries returns the audit entries of the services and service versions
+// deleted before the horizon, in their last state. Like their deletion, the
+// purge of the versions of a purged service is recorded as a single event of
+// the service.
+func purgedEntries(ctx context.Context, tx *sql.Tx, horizon string) ([]auditEntry, error) {
+	entries := []auditEntry{}
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, description, created_at, updated_at, deleted_at, revision
+		FROM services WHERE deleted_at < ?`, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query purged services: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Service
+		err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan purged service: %w", err)
+		}
+		entries = append(entries, auditEntry{auditPurge, auditService, s.ID, &s, nil})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to query purged services: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id, service_id, version, created_at, updated_at, deleted_at, revision
+		FROM service_versions WHERE deleted_at < ?
+		AND service_id NOT IN (SELECT id FROM services WHERE deleted_at < ?)`, horizon, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query purged service versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v ServiceVersion
+		err := rows.Scan(&v.ID, &v.ServiceID, &v.Version, &v.CreatedAt, &v.UpdatedAt, &v.DeletedAt, &v.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan purged service version: %w", err)
+		}
+		entries = append(entries, auditEntry{auditPurge, auditServiceVersion, v.ID, &v, nil})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to query purged service versions: %w", err)
+	}
+	return entries, nil
+}
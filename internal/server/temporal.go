@@ -0,0 +1,242 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// historyTimeFormat is the format of the validity times of the history, which
+// are compared as text.
+const historyTimeFormat = "2006-01-02 15:04:05.000"
+
+// Changes of the resources between two instants.
+const (
+	catalogAdded    = "added"
+	catalogRemoved  = "removed"
+	catalogModified = "modified"
+)
+
+// catalogColumns are the columns of the catalog tables that are read from
+// their history, along with rowid, revision and the timestamps.
+var catalogColumns = map[string]struct{ resourceType, columns string }{
+	"services":         {auditService, "resource_id AS id, name, description"},
+	"service_versions": {auditServiceVersion, "resource_id AS id, service_id, version"},
+}
+
+// CatalogChange is the change of a service or of a service version between
+// two instants.
+type CatalogChange struct {
+	// ResourceType is the type of the changed resource: service or
+	// service_version.
+	ResourceType string `json:"resource_type"`
+	// ResourceID identifies the changed resource at the later instant, or at
+	// the earlier one if it was removed.
+	ResourceID string `json:"resource_id"`
+	// ServiceID identifies the service of the changed resource.
+	ServiceID string `json:"service_id"`
+	// Change is added, removed or modified.
+	Change string `json:"change"`
+	// Changes lists the fields that differ between the two instants.
+	Changes []FieldChange `json:"changes"`
+}
+
+// parseInstant parses an RFC3339 query parameter of a request into the time
+// format of the history. It returns an empty string if the parameter is not
+// set.
+func parseInstant(r *http.Request, param string) (string, error) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return "", nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", newValidationError(FieldError{Field: param, Message: "must be a date-time"})
+	}
+	return at.UTC().Format(historyTimeFormat), nil
+}
+
+// catalogTable returns the table expression that services or service
+// versions are read from, along with its arguments: the table itself, or the
+// revisions of its rows that were valid at asOf, if set. The revisions have
+// the columns of the table, including the deleted_at of deleted rows.
+func catalogTable(table string, asOf string) (string, []interface{}) {
+	if asOf == "" {
+		return table, nil
+	}
+	catalog := catalogColumns[table]
+	//nolint:gosec // the columns and the resource type are constants
+	return fmt.Sprintf(`(SELECT resource_rowid AS rowid, %s, revision, created_at, updated_at, deleted_at
+		FROM service_history WHERE resource_type = '%s' AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?))`,
+		catalog.columns, catalog.resourceType), []interface{}{asOf, asOf}
+}
+
+// catalogDiff selects the services and service versions whose state differs
+// between the instants @from and @to, from the resources with a revision that
+// became or stopped being valid in between: their change, and their live
+// revision at both instants, if any. Their fields are compared in SQL, so that
+// resources changed back to their earlier state are left out of the count and
+// the pages.
+const catalogDiff = `WITH live AS (
+		SELECT * FROM service_history WHERE deleted_at IS NULL
+	), changed AS (
+		SELECT DISTINCT resource_type, resource_rowid FROM service_history
+		WHERE (valid_from > @from AND valid_from <= @to) OR (valid_to > @from AND valid_to <= @to)
+	)
+	SELECT c.resource_type, c.resource_rowid,
+		CASE WHEN b.seq IS NULL THEN 'added' WHEN a.seq IS NULL THEN 'removed' ELSE 'modified' END AS change,
+		b.service_id AS before_service_id, b.resource_id AS before_id, b.name AS before_name,
+		b.description AS before_description, b.version AS before_version,
+		a.service_id AS after_service_id, a.resource_id AS after_id, a.name AS after_name,
+		a.description AS after_description, a.version AS after_version
+	FROM changed c
+	LEFT JOIN live b ON b.resource_type = c.resource_type AND b.resource_rowid = c.resource_rowid
+		AND b.valid_from <= @from AND (b.valid_to IS NULL OR b.valid_to > @from)
+	LEFT JOIN live a ON a.resource_type = c.resource_type AND a.resource_rowid = c.resource_rowid
+		AND a.valid_from <= @to AND (a.valid_to IS NULL OR a.valid_to > @to)
+	WHERE (b.seq IS NULL) <> (a.seq IS NULL) OR (b.seq IS NOT NULL AND a.seq IS NOT NULL
+		AND (b.resource_id IS NOT a.resource_id OR b.name IS NOT a.name
+			OR b.description IS NOT a.description OR b.version IS NOT a.version))`
+
+// CatalogDiffHandler summarises the changes of the catalog between two
+// instants: the services and service versions added, removed and modified,
+// with the fields that differ, services first, in the order they were
+// created. Resources changed in between but back to their earlier state are
+// not listed.
+func (h *Handler) CatalogDiffHandler(w http.ResponseWriter, r *http.Request) {
+	instants := map[string]string{}
+	for _, param := range []string{"from", "to"} {
+		at, err := parseInstant(r, param)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		} else if at == "" {
+			WriteError(w, r, newValidationError(FieldError{Field: param, Message: "is required"}))
+			return
+		}
+		instants[param] = at
+	}
+	if instants["to"] < instants["from"] {
+		WriteError(w, r, newValidationError(FieldError{Field: "to", Message: "must not be before from"}))
+		return
+	}
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	from, to := sql.Named("from", instants["from"]), sql.Named("to", instants["to"])
+	summary, total, err := h.catalogDiffSummary(r, from, to)
+	if err != nil {
+		h.requestLogger(r).Error("failed to count catalog changes", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `SELECT resource_type, change, before_service_id, before_id,
+		before_name, before_description, before_version, after_service_id, after_id, after_name, after_description,
+		after_version FROM (`+catalogDiff+`)
+		ORDER BY resource_type = '`+auditServiceVersion+`', resource_rowid LIMIT @limit OFFSET @offset`,
+		from, to, sql.Named("limit", limit), sql.Named("offset", offset(page, limit)))
+	if err != nil {
+		h.requestLogger(r).Error("failed to query catalog changes", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	items := []CatalogChange{}
+	for rows.Next() {
+		var change CatalogChange
+		var beforeServiceID, beforeID, beforeName, beforeDescription, beforeVersion sql.NullString
+		var afterServiceID, afterID, afterName, afterDescription, afterVersion sql.NullString
+		err := rows.Scan(&change.ResourceType, &change.Change, &beforeServiceID, &beforeID, &beforeName,
+			&beforeDescription, &beforeVersion, &afterServiceID, &afterID, &afterName, &afterDescription,
+			&afterVersion)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan catalog changes", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		var before, after []FieldChange
+		if beforeID.Valid {
+			before = revisionFields(change.ResourceType, beforeID.String, beforeName, beforeDescription,
+				beforeVersion, sql.NullString{})
+		}
+		if afterID.Valid {
+			after = revisionFields(change.ResourceType, afterID.String, afterName, afterDescription,
+				afterVersion, sql.NullString{})
+			change.ResourceID, change.ServiceID = afterID.String, afterServiceID.String
+		} else {
+			// A removed resource is diffed against no fields at all.
+			after = make([]FieldChange, len(before))
+			for i, field := range before {
+				after[i] = FieldChange{Field: field.Field, To: nil}
+			}
+			change.ResourceID, change.ServiceID = beforeID.String, beforeServiceID.String
+		}
+		change.Changes = diffFields(before, after)
+		items = append(items, change)
+	}
+	if err := rows.Err(); err != nil {
+		h.requestLogger(r).Error("failed to read catalog changes", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":       r.URL.Query().Get("from"),
+		"to":         r.URL.Query().Get("to"),
+		"summary":    summary,
+		"items":      items,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// catalogDiffSummary counts the changes of the catalog between two instants,
+// by change and in total.
+func (h *Handler) catalogDiffSummary(r *http.Request, from, to sql.NamedArg) (map[string]int, int, error) {
+	rows, err := h.db.QueryContext(r.Context(), `SELECT change, COUNT(*) FROM (`+catalogDiff+`) GROUP BY change`,
+		from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summary := map[string]int{catalogAdded: 0, catalogRemoved: 0, catalogModified: 0}
+	total := 0
+	for rows.Next() {
+		var change string
+		var count int
+		if err := rows.Scan(&change, &count); err != nil {
+			return nil, 0, err
+		}
+		summary[change] = count
+		total += count
+	}
+	return summary, total, rows.Err()
+}
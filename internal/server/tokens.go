@@ -0,0 +1,420 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RefreshRequest represents the payload used to renew an access token.
+type RefreshRequest struct {
+	// RefreshToken is the refresh token returned along with the access token.
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RevokeRequest represents the payload used to revoke a token.
+type RevokeRequest struct {
+	// Token is the access or refresh token to revoke. The access token of the
+	// caller is revoked when it is omitted.
+	Token string `json:"token,omitempty"`
+}
+
+// revokedToken is the state of a revoked access token or refresh token family
+// recorded in the audit log.
+type revokedToken struct {
+	// Username is the user the token was issued to.
+	Username string `json:"username"`
+	// ExpiresAt is when the revoked access token expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// hashToken hashes an opaque token for storage. Refresh tokens are random, so
+// a fast hash is enough to keep them from being usable if the database leaks.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signAccessToken creates a JWT token for the user with a unique ID, carrying
+// the role of the user and the refresh token family of its login, valid from
+// now until it expires. It is signed with the signing key, or with the shared
+// secret when no keys are configured.
+func (h *Handler) signAccessToken(username string, role string, family string) (string, error) {
+	now := time.Now()
+	claims := tokenClaims{
+		Username:  username,
+		Roles:     []string{role},
+		SessionID: family,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    h.jwtIssuer,
+			Subject:   username,
+			Audience:  jwt.ClaimStrings{h.jwtAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(h.jwtTokenTimeout)),
+		},
+	}
+	var token string
+	var err error
+	if h.keys != nil {
+		token, err = h.keys.sign(claims)
+	} else {
+		token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.jwtSecret))
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to sign access token: %w", err)
+	}
+	return token, nil
+}
+
+// issueTokens creates an access token and a refresh token for the user. The
+// refresh token joins the given family, or starts a new one when family is
+// empty.
+func (h *Handler) issueTokens(ctx context.Context, db execer, username string, role string,
+	family string) (TokenResponse, error) {
+	if family == "" {
+		family = uuid.NewString()
+	}
+	accessToken, err := h.signAccessToken(username, role, family)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return TokenResponse{}, fmt.Errorf("unable to generate refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, family_id, username, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(), family, username, hashToken(refreshToken), now.Add(h.refreshTimeout), now)
+	if err != nil {
+		return TokenResponse{}, fmt.Errorf("unable to store refresh token: %w", err)
+	}
+	return TokenResponse{Token: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// isRevoked reports whether the access token with the given ID was revoked.
+func (h *Handler) isRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	var revoked int
+	err := h.db.QueryRow("SELECT 1 FROM revoked_tokens WHERE jti = ?", jti).Scan(&revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to query revoked token %s: %w", jti, err)
+	}
+	return true, nil
+}
+
+// revokeAccessToken adds the access token with the given ID to the denylist
+// until it expires, dropping the entries of the tokens that already expired.
+// It reports whether the token was not revoked yet.
+func revokeAccessToken(ctx context.Context, db execer, jti string, expiresAt time.Time) (bool, error) {
+	now := time.Now().UTC()
+	if _, err := db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < ?", now); err != nil {
+		return false, fmt.Errorf("unable to delete expired revoked tokens: %w", err)
+	}
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at, revoked_at) VALUES (?, ?, ?)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt.UTC(), now)
+	if err != nil {
+		return false, fmt.Errorf("unable to revoke token %s: %w", jti, err)
+	}
+	revoked, _ := result.RowsAffected()
+	return revoked > 0, nil
+}
+
+// revokeFamily revokes every refresh token of a family. It reports whether any
+// of them was not revoked yet.
+func revokeFamily(ctx context.Context, db execer, family string) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), family)
+	if err != nil {
+		return false, fmt.Errorf("unable to revoke refresh token family %s: %w", family, err)
+	}
+	revoked, _ := result.RowsAffected()
+	return revoked > 0, nil
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// new refresh token. Every refresh token can only be used once: presenting a
+// refresh token that was already used revokes its whole family, since either
+// the legitimate client or an attacker holds a stolen copy.
+func (h *Handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON payload from the request body.
+	var req RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var id, family, username string
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(r.Context(), `
+		SELECT id, family_id, username, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = ?`,
+		hashToken(req.RefreshToken)).Scan(&id, &family, &username, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.requestLogger(r).Warn("unknown refresh token")
+		WriteError(w, r, errInvalidRefresh)
+		return
+	} else if err != nil {
+		h.requestLogger(r).Error("failed to query refresh token", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	logger := h.requestLogger(r).With(zap.String("username", username), zap.String("family", family))
+
+	if usedAt.Valid && !revokedAt.Valid {
+		logger.Warn("refresh token reused, revoking its family")
+		_, err := revokeFamily(r.Context(), tx, family)
+		if err == nil {
+			err = h.commitWithAudit(r, tx,
+				auditEntry{auditRevoke, auditRefreshTokens, family, nil, revokedToken{Username: username}})
+		}
+		if err != nil {
+			logger.Error("failed to commit refresh token family revocation", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		WriteError(w, r, errInvalidRefresh)
+		return
+	}
+	if revokedAt.Valid || usedAt.Valid || time.Now().After(expiresAt) {
+		logger.Warn("revoked or expired refresh token")
+		WriteError(w, r, errInvalidRefresh)
+		return
+	}
+
+	// The new access token carries the current role of the user, unless the
+	// user has since been disabled or removed.
+	var role string
+	err = tx.QueryRowContext(r.Context(), "SELECT role FROM users WHERE username = ? AND NOT disabled",
+		username).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Warn("refresh token of an unknown or disabled user")
+		WriteError(w, r, errInvalidRefresh)
+		return
+	} else if err != nil {
+		logger.Error("failed to query user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	_, err = tx.ExecContext(r.Context(), "UPDATE refresh_tokens SET used_at = ? WHERE id = ?", time.Now().UTC(), id)
+	if err != nil {
+		logger.Error("failed to mark refresh token as used", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	response, err := h.issueTokens(r.Context(), tx, username, role, family)
+	if err != nil {
+		logger.Error("failed to issue tokens", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// RevokeTokenHandler revokes an access token or the family of a refresh token
+// before it expires. Without a payload, the access token used to authenticate
+// the request is revoked along with the refresh token family of its login,
+// logging the caller out. Revoking the tokens of other users requires the
+// users:manage permission.
+func (h *Handler) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		WriteError(w, r, errUnauthorized)
+		return
+	}
+
+	// Decode the optional JSON payload from the request body.
+	var req RevokeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if req.Token == "" {
+		if claims.TokenID == "" {
+			WriteError(w, r, newValidationError(FieldError{Field: "token", Message: "must be set for tokens without an ID"}))
+			return
+		}
+		entries, err := revokeSession(r.Context(), tx, claims)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, entries...)
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit token revocation", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		h.requestLogger(r).Info("token revoked", zap.String("jti", claims.TokenID),
+			zap.String("family", claims.SessionID))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// The token is either a refresh token issued by the server...
+	var family, owner string
+	err = tx.QueryRowContext(r.Context(), "SELECT family_id, username FROM refresh_tokens WHERE token_hash = ?",
+		hashToken(req.Token)).Scan(&family, &owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.requestLogger(r).Error("failed to query refresh token", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if err == nil {
+		if owner != claims.Username && !h.permissions.allows(claims.Roles, PermissionUsersManage) {
+			WriteError(w, r, errForbidden)
+			return
+		}
+		revoked, err := revokeFamily(r.Context(), tx, family)
+		if err == nil {
+			err = h.commitWithAudit(r, tx, revocationEntries(revoked,
+				auditEntry{auditRevoke, auditRefreshTokens, family, nil, revokedToken{Username: owner}})...)
+		}
+		if err != nil {
+			h.requestLogger(r).Error("failed to commit refresh token family revocation", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		h.requestLogger(r).Info("refresh token family revoked", zap.String("owner", owner),
+			zap.String("family", family))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// ...or an access token signed by the server. Expired tokens are accepted,
+	// since revoking them is harmless.
+	var token tokenClaims
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	if _, err := parser.ParseWithClaims(req.Token, &token, h.keyFunc); err != nil || token.ID == "" {
+		h.requestLogger(r).Warn("unable to revoke unknown token", zap.Error(err))
+		WriteError(w, r, newValidationError(FieldError{Field: "token", Message: "is not a token issued by the server"}))
+		return
+	}
+	if token.Username != claims.Username && !h.permissions.allows(claims.Roles, PermissionUsersManage) {
+		WriteError(w, r, errForbidden)
+		return
+	}
+	expiresAt := time.Now().Add(h.jwtTokenTimeout)
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.Time
+	}
+	revoked, err := revokeAccessToken(r.Context(), tx, token.ID, expiresAt)
+	if err == nil {
+		err = h.commitWithAudit(r, tx, revocationEntries(revoked, auditEntry{auditRevoke, auditAccessToken, token.ID, nil,
+			revokedToken{Username: token.Username, ExpiresAt: &expiresAt}})...)
+	}
+	if err != nil {
+		h.requestLogger(r).Error("failed to commit token revocation", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("token revoked", zap.String("owner", token.Username), zap.String("jti", token.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeSession revokes the access token of the claims along with the refresh
+// token family of its login, returning the audit entries of the tokens that
+// were not revoked yet.
+func revokeSession(ctx context.Context, tx *sql.Tx, claims *Claims) ([]auditEntry, error) {
+	expiresAt := claims.ExpiresAt.UTC()
+	revoked, err := revokeAccessToken(ctx, tx, claims.TokenID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	entries := revocationEntries(revoked, auditEntry{auditRevoke, auditAccessToken, claims.TokenID, nil,
+		revokedToken{Username: claims.Username, ExpiresAt: &expiresAt}})
+	if claims.SessionID == "" {
+		return entries, nil
+	}
+	revoked, err = revokeFamily(ctx, tx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, revocationEntries(revoked, auditEntry{auditRevoke, auditRefreshTokens, claims.SessionID,
+		nil, revokedToken{Username: claims.Username}})...), nil
+}
+
+// revocationEntries returns the audit entry of a revocation, or none when the
+// token was already revoked.
+func revocationEntries(revoked bool, entry auditEntry) []auditEntry {
+	if !revoked {
+		return nil
+	}
+	return []auditEntry{entry}
+}
@@ -0,0 +1,322 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User represents an account that can authenticate against the API.
+type User struct {
+	// Unique identifier for the user.
+	ID string `json:"id"`
+	// Username used to log in.
+	Username string `json:"username"`
+	// Role granted to the user: viewer, editor or admin.
+	Role string `json:"role"`
+	// Disabled is set for users that are no longer allowed to log in.
+	Disabled bool `json:"disabled"`
+	// Timestamp when the user was created.
+	CreatedAt time.Time `json:"created_at"`
+	// Timestamp when the user was last updated.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewUser represents the payload used to create a user.
+type NewUser struct {
+	// Username used to log in.
+	Username string `json:"username"`
+	// Password used to log in.
+	Password string `json:"password"`
+	// Role granted to the user: viewer, editor or admin. Defaults to viewer.
+	Role string `json:"role"`
+}
+
+// UserUpdate represents the payload used to update a user. Omitted fields are
+// left unchanged.
+type UserUpdate struct {
+	// Password replaces the password of the user.
+	Password *string `json:"password"`
+	// Role replaces the role granted to the user.
+	Role *string `json:"role"`
+	// Disabled enables or disables the user.
+	Disabled *bool `json:"disabled"`
+}
+
+// hashPassword hashes a password for storage in the users table.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// bootstrapAdmin creates the admin account from the configuration, unless a
+// user with the same username already exists.
+func (h *Handler) bootstrapAdmin(username string, password string) error {
+	if username == "" || password == "" {
+		return errors.New("admin username and password must be configured")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = h.db.Exec(`
+		INSERT INTO users (id, username, password_hash, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (username) DO NOTHING`,
+		uuid.NewString(), username, hash, RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("unable to create admin user %s: %w", username, err)
+	}
+	return nil
+}
+
+// CreateUserHandler creates a new user.
+func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON payload from the request body.
+	var newUser NewUser
+	err := json.NewDecoder(r.Body).Decode(&newUser)
+	if err != nil {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+	if strings.TrimSpace(newUser.Username) == "" {
+		WriteError(w, r, newValidationError(FieldError{Field: "username", Message: "must not be blank"}))
+		return
+	}
+	if strings.Contains(newUser.Username, externalUserSeparator) {
+		// Reserved for the users of trusted issuers.
+		WriteError(w, r, newValidationError(FieldError{Field: "username", Message: "must not contain " +
+			externalUserSeparator}))
+		return
+	}
+	if newUser.Username == systemActor {
+		// Reserved for the changes made by the server itself.
+		WriteError(w, r, newValidationError(FieldError{Field: "username", Message: "must not be " + systemActor}))
+		return
+	}
+	if newUser.Role == "" {
+		newUser.Role = RoleViewer
+	}
+
+	hash, err := hashPassword(newUser.Password)
+	if err != nil {
+		h.requestLogger(r).Error("failed to hash password", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Insert the user, rejecting usernames that are already taken.
+	id := uuid.NewString()
+	_, err = tx.ExecContext(r.Context(), `
+		INSERT INTO users (id, username, password_hash, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		id, newUser.Username, hash, newUser.Role)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		WriteError(w, r, errUserExists)
+		return
+	} else if err != nil {
+		h.requestLogger(r).Error("failed to insert user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	user, err := getUser(r.Context(), tx, id)
+	if err != nil {
+		h.requestLogger(r).Error("failed to fetch inserted user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if err := h.commitWithAudit(r, tx, auditEntry{auditCreate, auditUser, user.ID, nil, user}); err != nil {
+		h.requestLogger(r).Error("failed to commit user creation", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("user created", zap.String("user", user.Username), zap.String("role", user.Role))
+
+	// Set the response status to 201 Created and encode the new user as JSON.
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": user})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// ListUsersHandler lists the users one page at a time.
+func (h *Handler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		h.requestLogger(r).Warn("invalid pagination parameters", zap.Error(err))
+		WriteError(w, r, err)
+		return
+	}
+
+	var total int
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		h.requestLogger(r).Error("failed to count users", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT id, username, role, disabled, created_at, updated_at FROM users
+		ORDER BY created_at, id LIMIT ? OFFSET ?`,
+		limit, offset(page, limit))
+	if err != nil {
+		h.requestLogger(r).Error("failed to query users", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		err = rows.Scan(&user.ID, &user.Username, &user.Role, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			h.requestLogger(r).Error("failed to scan user", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		users = append(users, user)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":      users,
+		"pagination": newPagination(page, limit, total),
+	})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// UpdateUserHandler changes the password or role of a user, or disables it.
+func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Get the user ID from the URL path variables.
+	userID := mux.Vars(r)["userId"]
+
+	// Decode the JSON payload from the request body.
+	var update UserUpdate
+	err := json.NewDecoder(r.Body).Decode(&update)
+	if err != nil {
+		h.requestLogger(r).Error("invalid request payload", zap.Error(err))
+		WriteError(w, r, errInvalidPayload)
+		return
+	}
+
+	updateFields := []string{}
+	values := []interface{}{}
+	if update.Password != nil {
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			h.requestLogger(r).Error("failed to hash password", zap.Error(err))
+			WriteError(w, r, errInternal)
+			return
+		}
+		updateFields = append(updateFields, "password_hash = ?")
+		values = append(values, hash)
+	}
+	if update.Role != nil {
+		updateFields = append(updateFields, "role = ?")
+		values = append(values, *update.Role)
+	}
+	if update.Disabled != nil {
+		updateFields = append(updateFields, "disabled = ?")
+		values = append(values, *update.Disabled)
+	}
+	updateFields = append(updateFields, "updated_at = CURRENT_TIMESTAMP")
+	values = append(values, userID)
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = ?", strings.Join(updateFields, ", "))
+
+	tx, err := h.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		h.requestLogger(r).Error("failed to begin transaction", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	before, err := getUser(r.Context(), tx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, r, errUserNotFound)
+		return
+	} else if err != nil {
+		h.requestLogger(r).Error("failed to fetch user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	_, err = tx.ExecContext(r.Context(), query, values...)
+	if err != nil {
+		h.requestLogger(r).Error("failed to update user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+
+	user, err := getUser(r.Context(), tx, userID)
+	if err != nil {
+		h.requestLogger(r).Error("failed to fetch updated user", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	if err := h.commitWithAudit(r, tx, auditEntry{auditUpdate, auditUser, userID, before, user}); err != nil {
+		h.requestLogger(r).Error("failed to commit user update", zap.Error(err))
+		WriteError(w, r, errInternal)
+		return
+	}
+	h.requestLogger(r).Info("user updated", zap.String("user", user.Username), zap.Bool("disabled", user.Disabled))
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]interface{}{"item": user})
+	if err != nil {
+		h.requestLogger(r).Error("unable to encode response", zap.Error(err))
+	}
+}
+
+// getUser fetches the user with the given ID.
+func getUser(ctx context.Context, q dbtx, id string) (User, error) {
+	var user User
+	err := q.QueryRowContext(ctx, "SELECT id, username, role, disabled, created_at, updated_at FROM users WHERE id = ?",
+		id).Scan(&user.ID, &user.Username, &user.Role, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return User{}, fmt.Errorf("unable to query user %s: %w", id, err)
+	}
+	return user, nil
+}
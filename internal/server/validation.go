@@ -0,0 +1,270 @@
+// Copyright © 2024 Kong Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"go.uber.org/zap"
+)
+
+// Validator validates requests against the OpenAPI specification of the API.
+type Validator struct {
+	router routers.Router
+	logger *zap.Logger
+}
+
+// NewValidator creates a validator from the OpenAPI specification document.
+func NewValidator(spec []byte, logger *zap.Logger) (*Validator, error) {
+	// Keep the schema details out of the messages returned to clients.
+	openapi3.SchemaErrorDetailsDisabled = true
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load OpenAPI specification: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI specification: %w", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create OpenAPI router: %w", err)
+	}
+	return &Validator{
+		router: router,
+		logger: logger.With(zap.String("component", "validator")),
+	}, nil
+}
+
+// Middleware validates the path parameters, query parameters and body of
+// every request against the operation it targets, responding with a 400
+// listing the field violations before the handler runs. Requests for routes
+// that are not in the specification are passed through untouched.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The handlers have always decoded request bodies as JSON regardless
+		// of the content type, so keep accepting bodies sent without one.
+		if r.ContentLength != 0 && r.Header.Get("Content-Type") == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				// Authentication is enforced by the authentication middleware.
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			details := fieldErrors(err)
+			v.logger.Warn("request failed validation",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("violations", details))
+			WriteError(w, r, newValidationError(details...))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ResponseMiddleware validates the status, headers and body of every response
+// against the operation of its request, logging the responses that drift from
+// the specification. When enforce is set, such responses are replaced with a
+// 500 listing the violations so that contract breaks fail loudly.
+func (v *Validator) ResponseMiddleware(enforce bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := v.router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Hold the response back until it has been validated.
+			rec := newResponseRecorder()
+			next.ServeHTTP(rec, r)
+
+			// Injected faults break the contract on purpose.
+			if rec.header.Get(FaultInjectedHeader) != "" {
+				rec.writeTo(w)
+				return
+			}
+
+			input := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    r,
+					PathParams: pathParams,
+					Route:      route,
+				},
+				Status: rec.status,
+				Header: rec.header,
+				Body:   io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+				Options: &openapi3filter.Options{
+					MultiError:            true,
+					IncludeResponseStatus: true,
+				},
+			}
+			if err := openapi3filter.ValidateResponse(r.Context(), input); err != nil {
+				details := responseFieldErrors(err)
+				v.logger.Error("response does not match the OpenAPI specification",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Int("status", rec.status),
+					zap.Any("violations", details))
+				if enforce {
+					contractErr := NewAPIError(http.StatusInternalServerError, CodeContractViolation,
+						"Response does not match the API specification")
+					contractErr.Details = details
+					WriteError(w, r, contractErr)
+					return
+				}
+			}
+			rec.writeTo(w)
+		})
+	}
+}
+
+// fieldErrors flattens the errors returned by the OpenAPI validation into
+// field-level violations.
+func fieldErrors(err error) []FieldError {
+	// MultiError matches errors.As whenever one of its errors does, so it has
+	// to be unpacked before looking for the request errors it holds.
+	if multi, ok := err.(openapi3.MultiError); ok { //nolint:errorlint
+		var details []FieldError
+		for _, e := range multi {
+			details = append(details, fieldErrors(e)...)
+		}
+		return details
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return []FieldError{{Field: "request", Message: err.Error()}}
+	}
+
+	field := "body"
+	if reqErr.Parameter != nil {
+		field = reqErr.Parameter.Name
+	}
+
+	// Schema violations inside the body are reported against the offending
+	// property, and may themselves be a list of violations.
+	if nested, ok := reqErr.Err.(openapi3.MultiError); ok { //nolint:errorlint
+		var details []FieldError
+		for _, e := range nested {
+			details = append(details, schemaFieldError(field, reqErr.Parameter != nil, e))
+		}
+		return details
+	}
+	if reqErr.Err != nil {
+		return []FieldError{schemaFieldError(field, reqErr.Parameter != nil, reqErr.Err)}
+	}
+	return []FieldError{{Field: field, Message: reqErr.Reason}}
+}
+
+// schemaFieldError converts a single schema violation to a field violation.
+// Violations of a body property are reported using the property path.
+func schemaFieldError(field string, isParameter bool, err error) FieldError {
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return FieldError{Field: field, Message: err.Error()}
+	}
+	if path := schemaErr.JSONPointer(); !isParameter && len(path) > 0 {
+		field = strings.Join(path, ".")
+	}
+	return FieldError{Field: field, Message: schemaErr.Reason}
+}
+
+// responseFieldErrors flattens the errors returned by the OpenAPI response
+// validation into violations. Schema violations of the body are reported
+// against the offending property.
+func responseFieldErrors(err error) []FieldError {
+	if multi, ok := err.(openapi3.MultiError); ok { //nolint:errorlint
+		var details []FieldError
+		for _, e := range multi {
+			details = append(details, responseFieldErrors(e)...)
+		}
+		return details
+	}
+
+	var respErr *openapi3filter.ResponseError
+	if !errors.As(err, &respErr) || respErr.Err == nil {
+		return []FieldError{{Field: "response", Message: err.Error()}}
+	}
+	if nested, ok := respErr.Err.(openapi3.MultiError); ok { //nolint:errorlint
+		var details []FieldError
+		for _, e := range nested {
+			details = append(details, schemaFieldError("body", false, e))
+		}
+		return details
+	}
+	return []FieldError{schemaFieldError("body", false, respErr.Err)}
+}
+
+// responseRecorder buffers a response so it can be inspected before it is sent.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: http.Header{}, status: http.StatusOK}
+}
+
+// Header implements http.ResponseWriter.
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+// Write implements http.ResponseWriter.
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	return rec.body.Write(b)
+}
+
+// writeTo sends the buffered response to w.
+func (rec *responseRecorder) writeTo(w http.ResponseWriter) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(rec.body.Bytes())
+}
@@ -15,6 +15,7 @@ package main
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
 	"os"
 	"os/signal"
@@ -28,6 +29,11 @@ import (
 	"go.uber.org/zap"
 )
 
+// openAPISpec is the OpenAPI specification of the API, used to validate requests.
+//
+//go:embed openapi.yml
+var openAPISpec []byte
+
 var (
 	Version   string
 	Commit    string
@@ -72,9 +78,10 @@ func main() {
 
 	// Create the application
 	app, err := app.NewApp(app.Opts{
-		Config:   config,
-		Database: db,
-		Logger:   logger,
+		Config:      config,
+		Database:    db,
+		Logger:      logger,
+		OpenAPISpec: openAPISpec,
 	})
 	if err != nil {
 		panic(fmt.Sprintf("unable to create application: %v", err))
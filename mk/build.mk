@@ -20,6 +20,8 @@ APP_GO_VERSION ?= $(shell go version | awk '{print $$3;}')
 APP_DATE_FORMAT := +'%Y-%m-%dT%H:%M:%SZ'
 APP_BUILD_DATE ?= $(shell date $(APP_DATE_FORMAT))
 APP_PACKAGE := main
+# SQLite full-text search (FTS5) is only compiled into go-sqlite3 with this tag
+APP_BUILD_TAGS ?= sqlite_fts5
 
 define APP_LDFLAGS
 -X $(APP_PACKAGE).Version=$(APP_VERSION) \
@@ -31,7 +33,7 @@ endef
 
 .PHONY: build
 build:
-	@CGO_ENABLED=1 go build -ldflags "$(APP_LDFLAGS)" \
+	@CGO_ENABLED=1 go build -tags "$(APP_BUILD_TAGS)" -ldflags "$(APP_LDFLAGS)" \
 		-o "$(APP_DIR)/bin/$(APP_NAME)" "$(APP_DIR)"
 
 ifeq ($(APP_DOCKER_BUILD),)
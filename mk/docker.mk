@@ -15,11 +15,20 @@ docker-build:
 		-t candidate-take-home-exercise-sdet \
 		.
 
+APP_CONFIG ?= $(APP_DIR)/config.yml
+APP_KEYS ?= $(APP_DIR)/keys
+
 .PHONY: docker-run
 docker-run: docker-build
 	@docker run \
 		-p 18080:18080 \
 		--rm \
 		--name candidate-app \
-		-v "$(APP_DIR)/config.yml:/app/config.yml" \
+		-v "$(APP_CONFIG):/app/config.yml" \
+		-v "$(APP_KEYS):/app/keys:ro" \
 		candidate-take-home-exercise-sdet
+
+# Run the server with the configuration of the e2e tests
+.PHONY: docker-run-e2e
+docker-run-e2e: APP_CONFIG = $(APP_DIR)/test/config.yml
+docker-run-e2e: docker-run
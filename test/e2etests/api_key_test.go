@@ -0,0 +1,116 @@
+package e2etests
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// createApiKeyForRole creates a user with the given role and an API key for it
+func createApiKeyForRole(role string) (*service.ApiKeyApi, models.ApiKeyResponse) {
+	user, password := CreateUser(role)
+	keyApi := service.NewApiKeyApi(Client, baseUrl, AuthorizationApi.FetchToken(user.Username, password))
+	key_resp, _ := keyApi.CreateApiKey(models.NewApiKey{Name: framework.GetRandomName("ci")})
+	if key_resp.StatusCode != 201 {
+		framework.Logger.Error("Error in creating API key")
+	}
+	return keyApi, extractApiKeyResponse(key_resp)
+}
+
+/*
+Create an API key and read the catalog with it, without exchanging credentials for a token
+*/
+func TestApiKey_CreateAndAuthenticate(t *testing.T) {
+
+	key_resp, _ := ApiKeyApi.CreateApiKey(models.NewApiKey{Name: "ci-pipeline"})
+	assert.Equal(t, 201, key_resp.StatusCode)
+	created := extractApiKeyResponse(key_resp)
+	assert.True(t, strings.HasPrefix(created.Key, created.Item.Prefix))
+	assert.Equal(t, "ci-pipeline", created.Item.Name)
+	assert.Equal(t, Configuration.Username, created.Item.Username)
+	assert.Nil(t, created.Item.LastUsedAt)
+
+	list_resp, _ := ApiKeyApi.WithApiKey("GET", "/v1/services", created.Key)
+	assert.Equal(t, 200, list_resp.StatusCode)
+}
+
+/*
+List the API keys and verify the key itself is never returned, while its last use is recorded
+*/
+func TestApiKey_List_ShowsPrefixAndLastUse(t *testing.T) {
+
+	keyApi, created := createApiKeyForRole("viewer")
+	get_resp, _ := keyApi.WithApiKey("GET", "/v1/services", created.Key)
+	assert.Equal(t, 200, get_resp.StatusCode)
+
+	list_resp, _ := keyApi.ListApiKeys(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	body := framework.ResponseBodyToString(list_resp)
+	assert.NotContains(t, body, created.Key)
+	keys, _ := framework.ParseResponseBody[models.ListApiKeys](io.NopCloser(strings.NewReader(body)))
+	assert.Equal(t, 1, len(keys.Items))
+	assert.Equal(t, created.Item.ID, keys.Items[0].ID)
+	assert.Equal(t, created.Item.Prefix, keys.Items[0].Prefix)
+	assert.NotNil(t, keys.Items[0].LastUsedAt)
+}
+
+/*
+An API key acts with the role of its owner: a viewer key cannot change the catalog
+*/
+func TestApiKey_CarriesRoleOfOwner(t *testing.T) {
+
+	keyApi, created := createApiKeyForRole("viewer")
+	resp, _ := keyApi.WithApiKey("DELETE", "/v1/services/"+CreateService_Success().Item.ID, created.Key)
+	assert.Equal(t, 403, resp.StatusCode)
+	assert.Equal(t, "forbidden", extractErrorResponse(resp).Error.Code)
+}
+
+/*
+Revoke an API key; requests authenticated with it are rejected afterwards
+*/
+func TestApiKey_Revoke_RejectsKey(t *testing.T) {
+
+	keyApi, created := createApiKeyForRole("viewer")
+	revoke_resp, _ := keyApi.RevokeApiKey(created.Item.ID)
+	assert.Equal(t, 204, revoke_resp.StatusCode)
+
+	resp, _ := keyApi.WithApiKey("GET", "/v1/services", created.Key)
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "unauthorized", extractErrorResponse(resp).Error.Code)
+
+	list_resp, _ := keyApi.ListApiKeys(models.ListOptions{})
+	keys := extractListApiKeysResponse(list_resp)
+	assert.NotNil(t, keys.Items[0].RevokedAt)
+}
+
+/*
+A user cannot see or revoke the API keys of another user, while an admin can
+*/
+func TestApiKey_KeysOfAnotherUser(t *testing.T) {
+
+	_, victimKey := createApiKeyForRole("viewer")
+	attackerApi, _ := createApiKeyForRole("editor")
+
+	resp, _ := attackerApi.RevokeApiKey(victimKey.Item.ID)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "api_key_not_found", extractErrorResponse(resp).Error.Code)
+	get_resp, _ := attackerApi.WithApiKey("GET", "/v1/services", victimKey.Key)
+	assert.Equal(t, 200, get_resp.StatusCode)
+
+	admin_resp, _ := ApiKeyApi.RevokeApiKey(victimKey.Item.ID)
+	assert.Equal(t, 204, admin_resp.StatusCode)
+}
+
+/*
+Authenticate with an API key that was never issued and expect a 401
+*/
+func TestApiKey_UnknownKey_IsRejected(t *testing.T) {
+
+	resp, _ := ApiKeyApi.WithApiKey("GET", "/v1/services", "kc_"+framework.RandomString(43))
+	assert.Equal(t, 401, resp.StatusCode)
+}
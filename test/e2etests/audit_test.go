@@ -0,0 +1,222 @@
+package e2etests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// listAuditEvents returns the audit events matching the filters, most recent first
+func listAuditEvents(t *testing.T, filters map[string]string) []models.AuditEvent {
+	list_resp, _ := AuditApi.ListAuditEvents(filters)
+	assert.Equal(t, 200, list_resp.StatusCode)
+	return extractListAuditEventsResponse(list_resp).Items
+}
+
+/*
+Every change of a service is recorded with its state before and after, and the admin who made it
+*/
+func TestAudit_ServiceChangesAreRecorded(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	update_resp, _ := ServiceApi.UpdateService(serviceId, models.Service{Description: "audited"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+
+	events := listAuditEvents(t, map[string]string{"resource_type": "service", "resource_id": serviceId})
+	if !assert.Len(t, events, 4) {
+		return
+	}
+	for i, action := range []string{"restore", "delete", "update", "create"} {
+		assert.Equal(t, action, events[i].Action)
+		assert.Equal(t, Configuration.Username, events[i].Actor)
+		assert.NotEmpty(t, events[i].RequestID)
+	}
+
+	create := events[3]
+	assert.Equal(t, "null", string(create.Before))
+	var after models.Service
+	assert.NoError(t, json.Unmarshal(create.After, &after))
+	assert.Equal(t, "test service", after.Description)
+
+	update := events[2]
+	var before models.Service
+	assert.NoError(t, json.Unmarshal(update.Before, &before))
+	assert.NoError(t, json.Unmarshal(update.After, &after))
+	assert.Equal(t, "test service", before.Description)
+	assert.Equal(t, "audited", after.Description)
+	assert.Equal(t, update_resp.Header.Get("X-Request-ID"), update.RequestID)
+
+	var deleted, restored models.Service
+	assert.NoError(t, json.Unmarshal(events[1].After, &deleted))
+	assert.NotNil(t, deleted.DeletedAt)
+	assert.NoError(t, json.Unmarshal(events[0].After, &restored))
+	assert.Nil(t, restored.DeletedAt)
+}
+
+/*
+Changes of service versions are recorded, and deletions that change nothing are not
+*/
+func TestAudit_ServiceVersionChangesAreRecorded(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	delete_resp, _ = ServiceVersionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	events := listAuditEvents(t, map[string]string{"resource_id": versionId})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "delete", events[0].Action)
+		assert.Equal(t, "create", events[1].Action)
+		assert.Equal(t, "service_version", events[0].ResourceType)
+		var before models.ServiceVersion
+		assert.NoError(t, json.Unmarshal(events[0].Before, &before))
+		assert.Equal(t, "v1.0.0", before.Version)
+		assert.Nil(t, before.DeletedAt)
+	}
+}
+
+/*
+Changes of users and API keys are recorded without their secrets, under the user who made them
+*/
+func TestAudit_CredentialChangesAreRecorded(t *testing.T) {
+
+	user, password := CreateUser("editor")
+	role := "viewer"
+	update_resp, _ := UserApi.UpdateUser(user.ID, models.UserUpdate{Role: &role})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	events := listAuditEvents(t, map[string]string{"resource_type": "user", "resource_id": user.ID})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "update", events[0].Action)
+		assert.Equal(t, "create", events[1].Action)
+		assert.Contains(t, string(events[0].Before), `"role":"editor"`)
+		assert.Contains(t, string(events[0].After), `"role":"viewer"`)
+		assert.NotContains(t, string(events[1].After), password)
+	}
+
+	viewerKeys := service.NewApiKeyApi(Client, baseUrl, login(t, user.Username, password).Token)
+	key_resp, _ := viewerKeys.CreateApiKey(models.NewApiKey{Name: "audited"})
+	assert.Equal(t, 201, key_resp.StatusCode)
+	key := extractApiKeyResponse(key_resp)
+	revoke_resp, _ := viewerKeys.RevokeApiKey(key.Item.ID)
+	assert.Equal(t, 204, revoke_resp.StatusCode)
+	revoke_resp, _ = viewerKeys.RevokeApiKey(key.Item.ID)
+	assert.Equal(t, 204, revoke_resp.StatusCode)
+
+	events = listAuditEvents(t, map[string]string{"resource_type": "api_key", "resource_id": key.Item.ID})
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "revoke", events[0].Action)
+		assert.Equal(t, "create", events[1].Action)
+		for _, event := range events {
+			assert.Equal(t, user.Username, event.Actor)
+			assert.NotContains(t, string(event.After), key.Key)
+		}
+		assert.Contains(t, string(events[0].Before), `"revoked_at":null`)
+		assert.NotContains(t, string(events[0].After), `"revoked_at":null`)
+	}
+}
+
+/*
+Revocations of access tokens and refresh token families are recorded under the user who revoked them,
+and the revocation of a reused refresh token family under the system
+*/
+func TestAudit_TokenRevocationsAreRecorded(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	tokens := login(t, user.Username, password)
+	claims, _ := framework.ParseToken(tokens.Token)
+	revoke_resp, _ := AuthorizationApi.RevokeToken(tokens.Token, server.RevokeRequest{})
+	assert.Equal(t, 204, revoke_resp.StatusCode)
+
+	events := listAuditEvents(t, map[string]string{"actor": user.Username, "action": "revoke"})
+	if assert.Len(t, events, 2) {
+		types := []string{events[0].ResourceType, events[1].ResourceType}
+		assert.ElementsMatch(t, []string{"access_token", "refresh_token_family"}, types)
+		for _, event := range events {
+			assert.Equal(t, revoke_resp.Header.Get("X-Request-ID"), event.RequestID)
+			assert.Contains(t, string(event.After), `"username":"`+user.Username+`"`)
+			assert.NotContains(t, string(event.After), tokens.RefreshToken)
+			if event.ResourceType == "access_token" {
+				assert.Equal(t, claims.ID, event.ResourceID)
+			}
+		}
+	}
+
+	// Revoking a token again changes nothing and records nothing
+	admin_resp, _ := AuthorizationApi.RevokeToken(GetToken(), server.RevokeRequest{Token: tokens.RefreshToken})
+	assert.Equal(t, 204, admin_resp.StatusCode)
+	assert.Len(t, listAuditEvents(t, map[string]string{"actor": user.Username, "action": "revoke"}), 2)
+	assert.Empty(t, listAuditEvents(t, map[string]string{"actor": Configuration.Username, "resource_id": claims.SessionID}))
+
+	// The family of a reused refresh token is revoked by the system
+	tokens = login(t, user.Username, password)
+	claims, _ = framework.ParseToken(tokens.Token)
+	refresh_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 200, refresh_resp.StatusCode)
+	reuse_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 401, reuse_resp.StatusCode)
+
+	events = listAuditEvents(t, map[string]string{"resource_type": "refresh_token_family", "resource_id": claims.SessionID})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "system", events[0].Actor)
+		assert.Equal(t, reuse_resp.Header.Get("X-Request-ID"), events[0].RequestID)
+	}
+}
+
+/*
+The audit log is filtered by actor, action and time range
+*/
+func TestAudit_Filters(t *testing.T) {
+
+	editor, password := CreateUser("editor")
+	editorApi := service.NewServiceApi(Client, baseUrl, login(t, editor.Username, password).Token)
+	start := time.Now().Add(-time.Second)
+	for i := 0; i < 3; i++ {
+		create_resp, _ := editorApi.CreateService(framework.CreateServicePayload("", framework.GetRandomName("audited"), ""))
+		assert.Equal(t, 201, create_resp.StatusCode)
+	}
+
+	events := listAuditEvents(t, map[string]string{"actor": editor.Username})
+	assert.Len(t, events, 3)
+	events = listAuditEvents(t, map[string]string{"actor": editor.Username, "action": "delete"})
+	assert.Empty(t, events)
+	events = listAuditEvents(t, map[string]string{"actor": editor.Username, "since": start.UTC().Format(time.RFC3339)})
+	assert.Len(t, events, 3)
+	events = listAuditEvents(t, map[string]string{"actor": editor.Username, "until": start.UTC().Format(time.RFC3339)})
+	assert.Empty(t, events)
+
+	list_resp, _ := AuditApi.ListAuditEvents(map[string]string{"actor": editor.Username, "limit": "2"})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	page := extractListAuditEventsResponse(list_resp)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, 3, page.Pagination.Total)
+
+	list_resp, _ = AuditApi.ListAuditEvents(map[string]string{"since": "yesterday"})
+	assert.Equal(t, 400, list_resp.StatusCode)
+	assert.Equal(t, "validation_failed", extractErrorResponse(list_resp).Error.Code)
+}
+
+/*
+Only admins read the audit log
+*/
+func TestAudit_EditorIsForbidden(t *testing.T) {
+
+	editor, password := CreateUser("editor")
+	editorAudit := service.NewAuditApi(Client, baseUrl, login(t, editor.Username, password).Token)
+	list_resp, _ := editorAudit.ListAuditEvents(nil)
+	assert.Equal(t, 403, list_resp.StatusCode)
+	assert.True(t, strings.HasPrefix(list_resp.Header.Get("Content-Type"), "application/json"))
+}
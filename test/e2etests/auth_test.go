@@ -0,0 +1,196 @@
+package e2etests
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/stretchr/testify/assert"
+)
+
+// validClaims are the claims of a valid admin token, as minted by the server
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"username": Configuration.Username,
+		"roles":    []string{"admin"},
+		"jti":      framework.GetRandomName("jti"),
+		"iss":      Configuration.JWTIssuer,
+		"aud":      []string{Configuration.JWTAudience},
+		"sub":      Configuration.Username,
+		"iat":      now.Unix(),
+		"nbf":      now.Unix(),
+		"exp":      now.Add(time.Minute).Unix(),
+	}
+}
+
+// signToken signs claims with the key the server signs its tokens with
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	for _, key := range Configuration.JWTKeys {
+		if key.KID != Configuration.JWTSigningKey {
+			continue
+		}
+		pem, err := os.ReadFile("../../" + key.PrivateKeyFile)
+		assert.NoError(t, err)
+		var privateKey interface{}
+		if key.Algorithm == "ES256" {
+			privateKey, err = jwt.ParseECPrivateKeyFromPEM(pem)
+		} else {
+			privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(pem)
+		}
+		assert.NoError(t, err)
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+		token.Header["kid"] = key.KID
+		signed, err := token.SignedString(privateKey)
+		assert.NoError(t, err)
+		return signed
+	}
+	t.Fatalf("signing key %q is not configured", Configuration.JWTSigningKey)
+	return ""
+}
+
+/*
+Invoke the health check without a token; it is on the public allow-list
+GET /health
+*/
+func TestAuth_HealthCheck_IsPublic(t *testing.T) {
+
+	resp, _ := Client.HttpGet(baseUrl+"/health", "")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+}
+
+/*
+Invoke every protected route without a token and expect a 401 for each of them
+*/
+func TestAuth_ProtectedRoutes_RequireToken(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0")
+	paths := []string{
+		"/v1/services",
+		"/v1/search?q=payments",
+		"/v1/services/" + serviceId,
+		"/v1/services/" + serviceId + "/versions",
+	}
+	for _, path := range paths {
+		resp, _ := Client.HttpGet(baseUrl+path, "")
+		assert.Equal(t, 401, resp.StatusCode, path)
+		error_resp := extractErrorResponse(*resp)
+		assert.Equal(t, "unauthorized", error_resp.Error.Code, path)
+	}
+
+	resp, _ := Client.HttpDelete(baseUrl+"/v1/services/"+serviceId, "")
+	assert.Equal(t, 401, resp.StatusCode)
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+}
+
+/*
+Send an invalid payload without a token; authentication happens before the request is validated
+*/
+func TestAuth_UnauthenticatedRequest_IsRejectedBeforeValidation(t *testing.T) {
+
+	resp, _ := Client.HttpPost(baseUrl+"/v1/services", "", strings.NewReader(`{"name":5}`))
+	assert.Equal(t, 401, resp.StatusCode)
+	error_resp := extractErrorResponse(*resp)
+	assert.Equal(t, "unauthorized", error_resp.Error.Code)
+}
+
+/*
+Invoke a protected route with a token that expired and expect a 401
+*/
+func TestAuth_ExpiredToken_IsRejected(t *testing.T) {
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Minute - Configuration.JWTClockSkew).Unix()
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", signToken(t, claims))
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "token_expired", extractErrorResponse(*resp).Error.Code)
+}
+
+/*
+Request a token and verify it carries every registered claim
+*/
+func TestAuth_Token_CarriesRegisteredClaims(t *testing.T) {
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, framework.VerificationKey)
+	assert.NoError(t, err)
+	assert.Equal(t, Configuration.JWTIssuer, claims["iss"])
+	assert.Contains(t, claims["aud"], Configuration.JWTAudience)
+	assert.Equal(t, Configuration.Username, claims["sub"])
+	assert.NotNil(t, claims["iat"])
+	assert.NotNil(t, claims["nbf"])
+	assert.NotEmpty(t, claims["jti"])
+}
+
+/*
+Sign tokens that fail the validation of a single claim and expect each to be rejected with a distinct code
+*/
+func TestAuth_InvalidClaims_AreRejectedWithDistinctCodes(t *testing.T) {
+
+	future := time.Now().Add(time.Minute + Configuration.JWTClockSkew).Unix()
+	testCases := []struct {
+		name  string
+		claim string
+		value interface{}
+		code  string
+	}{
+		{"issuer", "iss", "https://untrusted.example.com", "invalid_token_issuer"},
+		{"missing issuer", "iss", nil, "invalid_token_issuer"},
+		{"audience", "aud", []string{"another-service"}, "invalid_token_audience"},
+		{"missing audience", "aud", nil, "invalid_token_audience"},
+		{"not before", "nbf", future, "token_not_yet_valid"},
+		{"issued at", "iat", future, "token_not_yet_valid"},
+		{"missing issued at", "iat", nil, "invalid_token"},
+		{"subject", "sub", "someone-else", "invalid_token_subject"},
+		{"missing subject", "sub", nil, "invalid_token_subject"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := validClaims()
+			if tc.value == nil {
+				delete(claims, tc.claim)
+			} else {
+				claims[tc.claim] = tc.value
+			}
+			resp, _ := Client.HttpGet(baseUrl+"/v1/services", signToken(t, claims))
+			assert.Equal(t, 401, resp.StatusCode)
+			assert.Equal(t, tc.code, extractErrorResponse(*resp).Error.Code)
+		})
+	}
+}
+
+/*
+Tokens that are only valid within the allowed clock skew are accepted
+*/
+func TestAuth_ClockSkew_IsAllowed(t *testing.T) {
+
+	claims := validClaims()
+	skew := Configuration.JWTClockSkew / 2
+	claims["nbf"] = time.Now().Add(skew).Unix()
+	claims["iat"] = time.Now().Add(skew).Unix()
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", signToken(t, claims))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	claims = validClaims()
+	claims["exp"] = time.Now().Add(-skew).Unix()
+	resp, _ = Client.HttpGet(baseUrl+"/v1/services", signToken(t, claims))
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+/*
+A token whose signature does not match its content is rejected as invalid
+*/
+func TestAuth_TamperedToken_IsRejected(t *testing.T) {
+
+	parts := strings.Split(signToken(t, validClaims()), ".")
+	other := strings.Split(signToken(t, validClaims()), ".")
+	tampered := parts[0] + "." + other[1] + "." + parts[2]
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", tampered)
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "invalid_token", extractErrorResponse(*resp).Error.Code)
+}
@@ -0,0 +1,202 @@
+package e2etests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Services carry their revision as ETag, which changes with every update
+*/
+func TestConcurrency_Service_ETagFollowsRevision(t *testing.T) {
+
+	serviceName := framework.GetRandomName("service")
+	create_resp, _ := CreateService(framework.CreateServicePayload(serviceName, serviceName, "etag"))
+	assert.Equal(t, 201, create_resp.StatusCode)
+	created := create_resp.Header.Get("ETag")
+	assert.Equal(t, `"1"`, created)
+	serviceId := extractServiceResponse(create_resp).Item.ID
+
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, created, get_resp.Header.Get("ETag"))
+
+	update_resp, _ := ServiceApi.UpdateServiceIfMatch(serviceId, models.Service{Description: "first edit"}, created)
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.Equal(t, `"2"`, update_resp.Header.Get("ETag"))
+
+	get_resp, _ = ServiceApi.GetService(serviceId)
+	assert.Equal(t, `"2"`, get_resp.Header.Get("ETag"))
+}
+
+/*
+An update made with the ETag of an older revision is rejected, leaving the change of the other tester in place
+*/
+func TestConcurrency_Service_StaleUpdateIsRejected(t *testing.T) {
+
+	service := CreateService_Success()
+	get_resp, _ := ServiceApi.GetService(service.Item.ID)
+	etag := get_resp.Header.Get("ETag")
+
+	// Two testers read the same revision, the first one wins
+	first_resp, _ := ServiceApi.UpdateServiceIfMatch(service.Item.ID, models.Service{Description: "first tester"}, etag)
+	assert.Equal(t, 200, first_resp.StatusCode)
+	second_resp, _ := ServiceApi.UpdateServiceIfMatch(service.Item.ID, models.Service{Description: "second tester"}, etag)
+	assert.Equal(t, 412, second_resp.StatusCode)
+	assert.Equal(t, "precondition_failed", extractErrorResponse(second_resp).Error.Code)
+
+	get_resp, _ = ServiceApi.GetService(service.Item.ID)
+	assert.Equal(t, "first tester", extractServiceResponse(get_resp).Item.Description)
+	assert.Equal(t, first_resp.Header.Get("ETag"), get_resp.Header.Get("ETag"))
+
+	// The second tester retries with the current revision
+	retry_resp, _ := ServiceApi.UpdateServiceIfMatch(service.Item.ID, models.Service{Description: "second tester"},
+		get_resp.Header.Get("ETag"))
+	assert.Equal(t, 200, retry_resp.StatusCode)
+	assert.Equal(t, "second tester", extractServiceResponse(retry_resp).Item.Description)
+}
+
+/*
+If-Match accepts a list of ETags, or * for any revision, and rejects weak or malformed ETags
+*/
+func TestConcurrency_Service_IfMatchValues(t *testing.T) {
+
+	service := CreateService_Success()
+	testCases := []struct {
+		ifMatch string
+		status  int
+	}{
+		{`"41", "1"`, 200},
+		{"*", 200},
+		{`W/"3"`, 412},
+		{"3", 412},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.ifMatch, func(t *testing.T) {
+			resp, _ := ServiceApi.UpdateServiceIfMatch(service.Item.ID, models.Service{Description: "if-match"}, tc.ifMatch)
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+/*
+A deletion made with the ETag of an older revision is rejected, and the service is kept
+*/
+func TestConcurrency_Service_StaleDeleteIsRejected(t *testing.T) {
+
+	service := CreateService_Success()
+	update_resp, _ := ServiceApi.UpdateService(service.Item.ID, models.Service{Description: "changed meanwhile"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	delete_resp, _ := ServiceApi.DeleteServiceIfMatch(service.Item.ID, `"1"`)
+	assert.Equal(t, 412, delete_resp.StatusCode)
+	get_resp, _ := ServiceApi.GetService(service.Item.ID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+
+	delete_resp, _ = ServiceApi.DeleteServiceIfMatch(service.Item.ID, update_resp.Header.Get("ETag"))
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	get_resp, _ = ServiceApi.GetService(service.Item.ID)
+	assert.Equal(t, 404, get_resp.StatusCode)
+}
+
+/*
+Updating a missing service is still a 404, whatever the If-Match header
+*/
+func TestConcurrency_Service_MissingServiceIsNotFound(t *testing.T) {
+
+	resp, _ := ServiceApi.UpdateServiceIfMatch("00000000-0000-0000-0000-000000000000",
+		models.Service{Description: "missing"}, `"1"`)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+/*
+Service versions carry their revision as ETag, and stale updates and deletions are rejected
+*/
+func TestConcurrency_ServiceVersion_StaleChangesAreRejected(t *testing.T) {
+
+	serviceVersion := CreateServiceVersion_Success()
+	serviceId := serviceVersion.Item.ServiceID
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, serviceVersion.Item.ID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	etag := get_resp.Header.Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	update_resp, _ := ServiceVersionApi.UpdateServiceVersionIfMatch(serviceId, serviceVersion.Item.ID,
+		models.ServiceVersion{Version: "v2.0.0"}, etag)
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.Equal(t, `"2"`, update_resp.Header.Get("ETag"))
+	versionId := serviceVersion.Item.ID
+
+	update_resp, _ = ServiceVersionApi.UpdateServiceVersionIfMatch(serviceId, versionId,
+		models.ServiceVersion{Version: "v3.0.0"}, etag)
+	assert.Equal(t, 412, update_resp.StatusCode)
+	assert.Equal(t, "precondition_failed", extractErrorResponse(update_resp).Error.Code)
+
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersionIfMatch(serviceId, versionId, etag)
+	assert.Equal(t, 412, delete_resp.StatusCode)
+	delete_resp, _ = ServiceVersionApi.DeleteServiceVersionIfMatch(serviceId, versionId, `"2"`)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+}
+
+/*
+A created service version is returned as stored, with its timestamps and the ETag of its first revision
+*/
+func TestConcurrency_ServiceVersion_CreationReturnsTheStoredVersion(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	create_resp, _ := ServiceVersionApi.CreateServiceVersion(serviceId,
+		framework.CreateServiceVersionPayload(serviceId, "", "v1.0.0"))
+	assert.Equal(t, 201, create_resp.StatusCode)
+	assert.Equal(t, `"1"`, create_resp.Header.Get("ETag"))
+	created := extractServiceVersionResponse(create_resp).Item
+	assert.False(t, created.CreatedAt.IsZero())
+
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, created.ID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, create_resp.Header.Get("ETag"), get_resp.Header.Get("ETag"))
+	stored := extractServiceVersionResponse(get_resp).Item
+	assert.True(t, stored.CreatedAt.Equal(created.CreatedAt))
+	assert.True(t, stored.UpdatedAt.Equal(created.UpdatedAt))
+}
+
+/*
+A version is updated again at the same URL with the ETag returned by its previous update, which returns the version as stored
+*/
+func TestConcurrency_ServiceVersion_UpdatesChainWithReturnedETag(t *testing.T) {
+
+	serviceVersion := CreateServiceVersion_Success()
+	serviceId, versionId := serviceVersion.Item.ServiceID, serviceVersion.Item.ID
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, versionId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+
+	update_resp, _ := ServiceVersionApi.UpdateServiceVersionIfMatch(serviceId, versionId,
+		models.ServiceVersion{Version: "v2.0.0"}, get_resp.Header.Get("ETag"))
+	assert.Equal(t, 200, update_resp.StatusCode)
+	updated := extractServiceVersionResponse(update_resp).Item
+	assert.Equal(t, versionId, updated.ID)
+	assert.Equal(t, serviceId, updated.ServiceID)
+	assert.Equal(t, "v2.0.0", updated.Version)
+	assert.False(t, updated.CreatedAt.IsZero())
+
+	update_resp, _ = ServiceVersionApi.UpdateServiceVersionIfMatch(serviceId, versionId,
+		models.ServiceVersion{Version: "v3.0.0"}, update_resp.Header.Get("ETag"))
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.Equal(t, `"3"`, update_resp.Header.Get("ETag"))
+	assert.Equal(t, versionId, extractServiceVersionResponse(update_resp).Item.ID)
+
+	get_resp, _ = ServiceVersionApi.GetServiceVersion(serviceId, versionId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, update_resp.Header.Get("ETag"), get_resp.Header.Get("ETag"))
+	assert.Equal(t, "v3.0.0", extractServiceVersionResponse(get_resp).Item.Version)
+
+	events := listAuditEvents(t, map[string]string{"resource_id": versionId, "action": "update"})
+	if assert.Len(t, events, 2) {
+		var after models.ServiceVersion
+		assert.NoError(t, json.Unmarshal(events[0].After, &after))
+		assert.Equal(t, versionId, after.ID)
+	}
+}
@@ -0,0 +1,135 @@
+package e2etests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+A service is only sent again once it changed since the ETag held by the client
+*/
+func TestConditionalGet_Service_IfNoneMatch(t *testing.T) {
+
+	service := CreateService_Success()
+	get_resp, _ := ServiceApi.GetService(service.Item.ID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	etag := get_resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, get_resp.Header.Get("Last-Modified"))
+	assert.Equal(t, Configuration.CacheControl.Default, get_resp.Header.Get("Cache-Control"))
+
+	cached_resp, _ := ServiceApi.GetServiceConditionally(service.Item.ID, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+	assert.Empty(t, framework.ResponseBodyToString(cached_resp))
+	assert.Equal(t, etag, cached_resp.Header.Get("ETag"))
+	assert.Equal(t, Configuration.CacheControl.Default, cached_resp.Header.Get("Cache-Control"))
+
+	// A weak comparison is enough for reads
+	cached_resp, _ = ServiceApi.GetServiceConditionally(service.Item.ID, map[string]string{"If-None-Match": "W/" + etag})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+
+	update_resp, _ := ServiceApi.UpdateService(service.Item.ID, models.Service{Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	changed_resp, _ := ServiceApi.GetServiceConditionally(service.Item.ID, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 200, changed_resp.StatusCode)
+	assert.Equal(t, "changed", extractServiceResponse(changed_resp).Item.Description)
+	assert.NotEqual(t, etag, changed_resp.Header.Get("ETag"))
+}
+
+/*
+A service is only sent again once it changed since the Last-Modified time held by the client
+*/
+func TestConditionalGet_Service_IfModifiedSince(t *testing.T) {
+
+	service := CreateService_Success()
+	get_resp, _ := ServiceApi.GetService(service.Item.ID)
+	lastModified := get_resp.Header.Get("Last-Modified")
+	modified, err := http.ParseTime(lastModified)
+	assert.NoError(t, err)
+
+	cached_resp, _ := ServiceApi.GetServiceConditionally(service.Item.ID, map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+
+	since := modified.Add(-time.Hour).Format(http.TimeFormat)
+	stale_resp, _ := ServiceApi.GetServiceConditionally(service.Item.ID, map[string]string{"If-Modified-Since": since})
+	assert.Equal(t, 200, stale_resp.StatusCode)
+	assert.Equal(t, service.Item.ID, extractServiceResponse(stale_resp).Item.ID)
+
+	// If-None-Match takes precedence over If-Modified-Since
+	both_resp, _ := ServiceApi.GetServiceConditionally(service.Item.ID,
+		map[string]string{"If-None-Match": `"0"`, "If-Modified-Since": lastModified})
+	assert.Equal(t, 200, both_resp.StatusCode)
+}
+
+/*
+Pages of services carry an ETag and the Cache-Control policy configured for the route
+*/
+func TestConditionalGet_ListServices(t *testing.T) {
+
+	CreateService_Success()
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	assert.Equal(t, "/v1/services", Configuration.CacheControl.Routes[0].Path)
+	assert.Equal(t, Configuration.CacheControl.Routes[0].Policy, list_resp.Header.Get("Cache-Control"))
+	// Deletions do not change the time of the items, so pages are only validated by their ETag
+	assert.Empty(t, list_resp.Header.Get("Last-Modified"))
+	etag := list_resp.Header.Get("ETag")
+
+	cached_resp, _ := ServiceApi.ListServicesConditionally(models.ListOptions{}, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+	since_resp, _ := ServiceApi.ListServicesConditionally(models.ListOptions{},
+		map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)})
+	assert.Equal(t, 200, since_resp.StatusCode)
+
+	// Another page is another representation
+	page_resp, _ := ServiceApi.ListServicesConditionally(models.ListOptions{Limit: 1}, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 200, page_resp.StatusCode)
+}
+
+/*
+The ETag of a page of versions changes when one of them is deleted
+*/
+func TestConditionalGet_ListServiceVersions_DeletionChangesETag(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0", "v2.0.0")
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions := extractListServiceVersionsResponse(list_resp)
+	etag := list_resp.Header.Get("ETag")
+
+	cached_resp, _ := ServiceVersionApi.ListServiceVersionsConditionally(serviceId, models.ListOptions{},
+		map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versions.Items[1].ID)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	changed_resp, _ := ServiceVersionApi.ListServiceVersionsConditionally(serviceId, models.ListOptions{},
+		map[string]string{"If-None-Match": etag})
+	assert.Equal(t, 200, changed_resp.StatusCode)
+	assert.Len(t, extractListServiceVersionsResponse(changed_resp).Items, 1)
+}
+
+/*
+A service version is only sent again once it changed since the ETag held by the client
+*/
+func TestConditionalGet_ServiceVersion_IfNoneMatch(t *testing.T) {
+
+	serviceVersion := CreateServiceVersion_Success()
+	serviceId := serviceVersion.Item.ServiceID
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, serviceVersion.Item.ID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, Configuration.CacheControl.Default, get_resp.Header.Get("Cache-Control"))
+
+	cached_resp, _ := ServiceVersionApi.GetServiceVersionConditionally(serviceId, serviceVersion.Item.ID,
+		map[string]string{"If-None-Match": get_resp.Header.Get("ETag")})
+	assert.Equal(t, 304, cached_resp.StatusCode)
+
+	any_resp, _ := ServiceVersionApi.GetServiceVersionConditionally(serviceId, serviceVersion.Item.ID,
+		map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, 304, any_resp.StatusCode)
+}
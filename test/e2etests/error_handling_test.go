@@ -0,0 +1,75 @@
+package e2etests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Invoke a route that does not exist and verify the JSON error envelope
+*/
+func TestErrorHandling_UnknownRoute_ReturnsJsonNotFound(t *testing.T) {
+
+	resp, _ := Client.HttpGet(ServiceApi.BaseURL+"/v1/unknown", token)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	error_resp := extractErrorResponse(*resp)
+	assert.Equal(t, "not_found", error_resp.Error.Code)
+	assert.NotEmpty(t, error_resp.Error.Message)
+}
+
+/*
+Invoke a known route with an unsupported method and verify the JSON error envelope
+*/
+func TestErrorHandling_UnsupportedMethod_ReturnsJsonMethodNotAllowed(t *testing.T) {
+
+	resp, _ := Client.HttpDelete(ServiceApi.BaseURL+"/v1/services", token)
+	assert.Equal(t, 405, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	error_resp := extractErrorResponse(*resp)
+	assert.Equal(t, "method_not_allowed", error_resp.Error.Code)
+}
+
+/*
+Invoke an authenticated route without a token and verify
+1. The error envelope has the unauthorized code
+2. The request id in the body matches the X-Request-ID response header
+*/
+func TestErrorHandling_Unauthorized_IncludesRequestId(t *testing.T) {
+
+	resp, _ := Client.HttpGet(ServiceApi.BaseURL+"/v1/services", "")
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	requestId := resp.Header.Get("X-Request-ID")
+	assert.NotEmpty(t, requestId)
+	error_resp := extractErrorResponse(*resp)
+	assert.Equal(t, "unauthorized", error_resp.Error.Code)
+	assert.Equal(t, requestId, error_resp.Error.RequestID)
+}
+
+/*
+Send a request ID with the request and verify
+1. A valid ID, such as a UUID, is echoed back and used in the error envelope
+2. An ID with other characters, or too long, is replaced by one of the server
+*/
+func TestErrorHandling_ClientRequestId(t *testing.T) {
+
+	clientId := uuid.NewString()
+	resp, _ := Client.HttpDo(http.MethodGet, ServiceApi.BaseURL+"/v1/services", map[string]string{"X-Request-ID": clientId}, nil)
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, clientId, resp.Header.Get("X-Request-ID"))
+	assert.Equal(t, clientId, extractErrorResponse(*resp).Error.RequestID)
+
+	for _, invalidId := range []string{"forged id\t<script>", strings.Repeat("a", 65)} {
+		resp, _ = Client.HttpDo(http.MethodGet, ServiceApi.BaseURL+"/v1/services", map[string]string{"X-Request-ID": invalidId}, nil)
+		assert.Equal(t, 401, resp.StatusCode)
+		requestId := resp.Header.Get("X-Request-ID")
+		assert.NotEqual(t, invalidId, requestId)
+		_, err := uuid.Parse(requestId)
+		assert.NoError(t, err)
+	}
+}
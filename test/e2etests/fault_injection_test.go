@@ -0,0 +1,166 @@
+package e2etests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// getServiceRoute is the route faults are injected in by the tests
+const getServiceRoute = "/v1/services/{serviceId}"
+
+// injectFaults enables fault injection with the given rules until the end of the test, then restores the faults of
+// the configuration
+func injectFaults(t *testing.T, seed int64, rules ...models.FaultRule) {
+	resp, _ := FaultApi.GetFaults()
+	assert.Equal(t, 200, resp.StatusCode)
+	original, _ := framework.ParseResponseBody[models.FaultInjection](resp.Body)
+	t.Cleanup(func() {
+		resp, _ := FaultApi.SetFaults(original)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	resp, _ = FaultApi.SetFaults(models.FaultInjection{Enabled: true, Seed: seed, Rules: rules})
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+// createServiceForFaults creates a service to request while faults are injected
+func createServiceForFaults(t *testing.T) string {
+	resp, _ := CreateService(framework.CreateServicePayload(framework.GetRandomName("service"),
+		framework.GetRandomName("faults"), "faults"))
+	assert.Equal(t, 201, resp.StatusCode)
+	return extractServiceResponse(resp).Item.ID
+}
+
+/*
+Fault injection is disabled by default, with the faults of the configuration ready to be enabled
+*/
+func TestFaultInjection_DisabledByDefault(t *testing.T) {
+
+	resp, _ := FaultApi.GetFaults()
+	assert.Equal(t, 200, resp.StatusCode)
+	faults, _ := framework.ParseResponseBody[models.FaultInjection](resp.Body)
+	assert.False(t, faults.Enabled)
+	assert.NotZero(t, faults.Seed)
+	assert.Len(t, faults.Rules, len(Configuration.FaultInjection.Rules))
+}
+
+/*
+Requests of a route with an error status fault are answered with that status, other routes are not affected
+*/
+func TestFaultInjection_ErrorStatus(t *testing.T) {
+
+	serviceId := createServiceForFaults(t)
+	injectFaults(t, 1, models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 1, Status: 503})
+
+	resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "status", resp.Header.Get("X-Fault-Injected"))
+	assert.Equal(t, "injected_fault", extractErrorResponse(resp).Error.Code)
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	assert.Empty(t, list_resp.Header.Get("X-Fault-Injected"))
+}
+
+/*
+The same seed injects faults in the same requests
+*/
+func TestFaultInjection_SeedIsReproducible(t *testing.T) {
+
+	serviceId := createServiceForFaults(t)
+	rule := models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 0.5, Status: 500}
+	statuses := func() []int {
+		injectFaults(t, 42, rule)
+		var statuses []int
+		for i := 0; i < 20; i++ {
+			resp, _ := ServiceApi.GetService(serviceId)
+			statuses = append(statuses, resp.StatusCode)
+		}
+		return statuses
+	}
+
+	first := statuses()
+	assert.Contains(t, first, 200)
+	assert.Contains(t, first, 500)
+	assert.Equal(t, first, statuses())
+}
+
+/*
+Latency faults delay the requests, which are then handled normally
+*/
+func TestFaultInjection_Latency(t *testing.T) {
+
+	serviceId := createServiceForFaults(t)
+	injectFaults(t, 1, models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 1, LatencyMs: 300})
+
+	start := time.Now()
+	resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+/*
+Hang faults hold the requests until the client gives up, and drop faults close the connection without a response
+*/
+func TestFaultInjection_HangAndDrop(t *testing.T) {
+
+	serviceId := createServiceForFaults(t)
+	injectFaults(t, 1,
+		models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 1, Hang: true},
+		models.FaultRule{Method: "DELETE", Path: getServiceRoute, Probability: 1, Drop: true})
+
+	req, _ := http.NewRequest(http.MethodGet, baseUrl+"/v1/services/"+serviceId, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	_, err := (&http.Client{Timeout: 500 * time.Millisecond}).Do(req)
+	assert.ErrorContains(t, err, "Timeout")
+
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	_, apiErr := Client.HttpDo(http.MethodDelete, baseUrl+"/v1/services/"+serviceId, headers, nil)
+	assert.Error(t, apiErr.Error)
+}
+
+/*
+Only admins change the faults
+*/
+func TestFaultInjection_RequiresAdmin(t *testing.T) {
+
+	editor, password := CreateUser("editor")
+	faultApi := service.NewFaultApi(Client, baseUrl, login(t, editor.Username, password).Token)
+	resp, _ := faultApi.GetFaults()
+	assert.Equal(t, 403, resp.StatusCode)
+	resp, _ = faultApi.SetFaults(models.FaultInjection{Enabled: true})
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+/*
+Invalid rules are rejected
+*/
+func TestFaultInjection_InvalidRules(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		rule  models.FaultRule
+		field string
+	}{
+		{"probability", models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 2, Status: 500}, "rules.0.probability"},
+		{"status", models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 1, Status: 200}, "rules.0.status"},
+		{"no fault", models.FaultRule{Method: "GET", Path: getServiceRoute, Probability: 1}, "rules.0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := FaultApi.SetFaults(models.FaultInjection{Enabled: true, Rules: []models.FaultRule{tc.rule}})
+			assert.Equal(t, 400, resp.StatusCode)
+			error_resp := extractErrorResponse(resp)
+			assert.Equal(t, "validation_failed", error_resp.Error.Code)
+			if assert.NotEmpty(t, error_resp.Error.Details) {
+				assert.Equal(t, tc.field, error_resp.Error.Details[0].Field)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package e2etests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// countServicesNamed returns the number of services whose name matches the keyword
+func countServicesNamed(t *testing.T, keyword string) int {
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: keyword})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	return extractListServicesResponse(list_resp).Pagination.Total
+}
+
+/*
+Retrying a service creation with the same key replays the first response instead of creating a duplicate
+*/
+func TestIdempotency_CreateService_RetryIsReplayed(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(12))
+	payload := framework.CreateServicePayload("", "Idempotent "+keyword, "retried creation")
+	key := uuid.NewString()
+
+	first_resp, _ := ServiceApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, first_resp.StatusCode)
+	assert.Empty(t, first_resp.Header.Get("Idempotent-Replayed"))
+	first := extractServiceResponse(first_resp)
+
+	retry_resp, _ := ServiceApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, retry_resp.StatusCode)
+	assert.Equal(t, "true", retry_resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, first_resp.Header.Get("ETag"), retry_resp.Header.Get("ETag"))
+	assert.Equal(t, first, extractServiceResponse(retry_resp))
+
+	assert.Equal(t, 1, countServicesNamed(t, keyword))
+}
+
+/*
+Reusing a key for another payload or another route is rejected
+*/
+func TestIdempotency_KeyReusedForAnotherRequest(t *testing.T) {
+
+	key := uuid.NewString()
+	resp, _ := ServiceApi.CreateServiceWithIdempotencyKey(framework.CreateServicePayload("", "first", ""), key)
+	assert.Equal(t, 201, resp.StatusCode)
+	serviceId := extractServiceResponse(resp).Item.ID
+
+	resp, _ = ServiceApi.CreateServiceWithIdempotencyKey(framework.CreateServicePayload("", "second", ""), key)
+	assert.Equal(t, 422, resp.StatusCode)
+	assert.Equal(t, "idempotency_key_reused", extractErrorResponse(resp).Error.Code)
+
+	resp, _ = ServiceVersionApi.CreateServiceVersionWithIdempotencyKey(serviceId,
+		framework.CreateServiceVersionPayload(serviceId, "", ""), key)
+	assert.Equal(t, 422, resp.StatusCode)
+}
+
+/*
+Keys are scoped to the user, so users do not see each other's responses
+*/
+func TestIdempotency_KeysArePerUser(t *testing.T) {
+
+	editor, password := CreateUser("editor")
+	editorApi := service.NewServiceApi(Client, baseUrl, login(t, editor.Username, password).Token)
+	payload := framework.CreateServicePayload("", framework.GetRandomName("shared-key"), "")
+	key := uuid.NewString()
+
+	admin_resp, _ := ServiceApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, admin_resp.StatusCode)
+	editor_resp, _ := editorApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, editor_resp.StatusCode)
+	assert.Empty(t, editor_resp.Header.Get("Idempotent-Replayed"))
+	assert.NotEqual(t, extractServiceResponse(admin_resp).Item.ID, extractServiceResponse(editor_resp).Item.ID)
+}
+
+/*
+Retrying a service version creation with the same key replays the first response
+*/
+func TestIdempotency_CreateServiceVersion_RetryIsReplayed(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	payload := framework.CreateServiceVersionPayload(serviceId, "", "v1.0.0")
+	key := uuid.NewString()
+
+	first_resp, _ := ServiceVersionApi.CreateServiceVersionWithIdempotencyKey(serviceId, payload, key)
+	assert.Equal(t, 201, first_resp.StatusCode)
+	retry_resp, _ := ServiceVersionApi.CreateServiceVersionWithIdempotencyKey(serviceId, payload, key)
+	assert.Equal(t, 201, retry_resp.StatusCode)
+	assert.Equal(t, "true", retry_resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, extractServiceVersionResponse(first_resp).Item.ID, extractServiceVersionResponse(retry_resp).Item.ID)
+
+	assert.Len(t, listServiceVersionsAndExtractTheList(serviceId).Items, 1)
+}
+
+/*
+Keys expire after the idempotency window, after which the same request creates another service
+*/
+func TestIdempotency_KeysExpire(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(12))
+	payload := framework.CreateServicePayload("", "Expiring "+keyword, "")
+	key := uuid.NewString()
+
+	resp, _ := ServiceApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, resp.StatusCode)
+	time.Sleep(Configuration.Idempotency.Window + time.Second)
+
+	resp, _ = ServiceApi.CreateServiceWithIdempotencyKey(payload, key)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, countServicesNamed(t, keyword))
+}
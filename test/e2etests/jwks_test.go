@@ -0,0 +1,102 @@
+package e2etests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/stretchr/testify/assert"
+)
+
+// signWithRetiredKey signs claims with the private key of the retired RS256 key, as if the token was issued before
+// the last rotation
+func signWithRetiredKey(t *testing.T, kid string, claims jwt.MapClaims) string {
+	pem, err := os.ReadFile("../../keys/dev-rs256.pem")
+	assert.NoError(t, err)
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	assert.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(privateKey)
+	assert.NoError(t, err)
+	return signed
+}
+
+/*
+The JWKS publishes the public part of every configured key, without the private parts
+*/
+func TestJwks_PublishesConfiguredKeys(t *testing.T) {
+
+	resp, _ := Client.HttpGet(baseUrl+"/.well-known/jwks.json", "")
+	assert.Equal(t, 200, resp.StatusCode)
+	body := framework.ResponseBodyToString(*resp)
+	assert.NotContains(t, body, `"d"`)
+
+	jwks, err := framework.FetchJWKS()
+	assert.NoError(t, err)
+	kids := map[string]string{}
+	for _, key := range jwks.Keys {
+		kids[key.Kid] = key.Alg
+		assert.Equal(t, "sig", key.Use)
+	}
+	for _, key := range Configuration.JWTKeys {
+		assert.Equal(t, key.Algorithm, kids[key.KID], key.KID)
+	}
+}
+
+/*
+Tokens carry the kid of the signing key and are verified with the JWKS alone
+*/
+func TestJwks_TokenIsSignedWithActiveKey(t *testing.T) {
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, Configuration.JWTSigningKey, parsed.Header["kid"])
+	assert.NotEqual(t, "HS256", parsed.Method.Alg())
+
+	valid, err := framework.TokenHasUsernameClaim(token, Configuration.Username)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+}
+
+/*
+Tokens signed with a retired key that is still configured keep working after a rotation
+*/
+func TestJwks_RetiredKey_StillVerifiesTokens(t *testing.T) {
+
+	retiredToken := signWithRetiredKey(t, "dev-rs256-1", validClaims())
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", retiredToken)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+/*
+Tokens with an unknown kid, or signed with the shared secret, are rejected
+*/
+func TestJwks_UntrustedTokens_AreRejected(t *testing.T) {
+
+	unknownKid := signWithRetiredKey(t, "unknown-kid", validClaims())
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", unknownKid)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	sharedSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte(Configuration.JWTSecret))
+	assert.NoError(t, err)
+	resp, _ = Client.HttpGet(baseUrl+"/v1/services", sharedSecret)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+/*
+A token signed with HS256 using the public key of a configured key as the secret is rejected
+*/
+func TestJwks_AlgorithmConfusion_IsRejected(t *testing.T) {
+
+	publicKey, err := os.ReadFile("../../keys/dev-rs256.pub.pem")
+	assert.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	forged.Header["kid"] = "dev-rs256-1"
+	forgedToken, err := forged.SignedString(publicKey)
+	assert.NoError(t, err)
+
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", forgedToken)
+	assert.Equal(t, 401, resp.StatusCode)
+}
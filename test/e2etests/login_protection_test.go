@@ -0,0 +1,195 @@
+package e2etests
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// randomClientIP returns an address no other test logs in from, so that the failures of a test do not lock out others
+func randomClientIP() string {
+	return fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), 1+rand.Intn(254))
+}
+
+// failLogins fails to log in as the user the given number of times
+func failLogins(t *testing.T, username string, clientIP string, times int) {
+	for i := 0; i < times; i++ {
+		resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(username, "wrong-password"), clientIP)
+		assert.Equal(t, 401, resp.StatusCode)
+	}
+}
+
+// retryAfter returns the delay of the Retry-After header of a response
+func retryAfter(t *testing.T, resp http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	assert.NoError(t, err)
+	return time.Duration(seconds) * time.Second
+}
+
+/*
+Unknown users, disabled users and wrong passwords are rejected with the same error
+*/
+func TestLoginProtection_UniformCredentialErrors(t *testing.T) {
+
+	disabledUser, disabledPassword := CreateUser("viewer")
+	disabled := true
+	update_resp, _ := UserApi.UpdateUser(disabledUser.ID, models.UserUpdate{Disabled: &disabled})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	user, _ := CreateUser("viewer")
+
+	clientIP := randomClientIP()
+	testCases := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"unknown user", framework.GetRandomName("unknown"), "password"},
+		{"disabled user", disabledUser.Username, disabledPassword},
+		{"wrong password", user.Username, "wrong-password"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(tc.username, tc.password), clientIP)
+			assert.Equal(t, 401, resp.StatusCode)
+			error_resp := extractErrorResponse(resp)
+			assert.Equal(t, "invalid_credentials", error_resp.Error.Code)
+			assert.Equal(t, "Invalid username or password", error_resp.Error.Message)
+		})
+	}
+}
+
+/*
+A username is locked out after too many failures, for longer after every further failure, and even with the right
+password; logging in once the lockout expires resets the failures
+*/
+func TestLoginProtection_UsernameLockout_BacksOffExponentially(t *testing.T) {
+
+	maxFailures := Configuration.LoginProtection.MaxFailuresPerUsername
+	user, password := CreateUser("viewer")
+	failLogins(t, user.Username, randomClientIP(), maxFailures)
+
+	// Locked out, even from another client and with the right password
+	resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, password), randomClientIP())
+	assert.Equal(t, 429, resp.StatusCode)
+	firstLockout := retryAfter(t, resp)
+	assert.Equal(t, Configuration.LoginProtection.BaseLockout, firstLockout)
+
+	// A failure after the lockout doubles it
+	time.Sleep(firstLockout)
+	failLogins(t, user.Username, randomClientIP(), 1)
+	resp, _ = AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, password), randomClientIP())
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "too_many_login_attempts", extractErrorResponse(resp).Error.Code)
+	secondLockout := retryAfter(t, resp)
+	assert.Equal(t, 2*firstLockout, secondLockout)
+
+	// Once the lockout expires, logging in resets the failures
+	time.Sleep(secondLockout)
+	login(t, user.Username, password)
+	failLogins(t, user.Username, randomClientIP(), maxFailures-1)
+	login(t, user.Username, password)
+}
+
+/*
+Concurrent failed logins cannot exceed the maximum number of failures of a username together
+*/
+func TestLoginProtection_ConcurrentFailures(t *testing.T) {
+
+	maxFailures := Configuration.LoginProtection.MaxFailuresPerUsername
+	user, _ := CreateUser("viewer")
+
+	statuses := make(chan int, 4*maxFailures)
+	var wg sync.WaitGroup
+	for i := 0; i < 4*maxFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, "wrong-password"),
+				randomClientIP())
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.LessOrEqual(t, counts[401], maxFailures)
+	assert.Equal(t, 4*maxFailures, counts[401]+counts[429])
+}
+
+/*
+A client is locked out after too many failures, whatever the usernames, without locking out the users from other
+clients
+*/
+func TestLoginProtection_ClientLockout(t *testing.T) {
+
+	clientIP := randomClientIP()
+	for i := 0; i < Configuration.LoginProtection.MaxFailuresPerIP; i++ {
+		failLogins(t, framework.GetRandomName("user"), clientIP, 1)
+	}
+
+	user, password := CreateUser("viewer")
+	resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, password), clientIP)
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	resp, _ = AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, password), randomClientIP())
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+/*
+Every login attempt is recorded in the audit trail, listed to admins only
+*/
+func TestLoginProtection_AuditTrail(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	clientIP := randomClientIP()
+	failLogins(t, user.Username, clientIP, 1)
+	resp, _ := AuthorizationApi.CreateTokenFrom(framework.CreateCredentialsReqBody(user.Username, password), clientIP)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	list_resp, _ := AuthorizationApi.ListLoginAttempts(token, user.Username)
+	assert.Equal(t, 200, list_resp.StatusCode)
+	attempts, _ := framework.ParseResponseBody[models.ListLoginAttempts](list_resp.Body)
+	assert.Equal(t, 2, attempts.Pagination.Total)
+	if assert.Len(t, attempts.Items, 2) {
+		// Most recent attempts first
+		assert.Equal(t, "succeeded", attempts.Items[0].Outcome)
+		assert.Equal(t, "invalid_credentials", attempts.Items[1].Outcome)
+		for _, attempt := range attempts.Items {
+			assert.Equal(t, user.Username, attempt.Username)
+			assert.Equal(t, clientIP, attempt.IP)
+			assert.NotEmpty(t, attempt.RequestID)
+		}
+	}
+
+	list_resp, _ = AuthorizationApi.ListLoginAttempts(login(t, user.Username, password).Token, user.Username)
+	assert.Equal(t, 403, list_resp.StatusCode)
+}
+
+/*
+The usernames of login attempts are truncated to the length of the longest username before they are recorded
+*/
+func TestLoginProtection_AuditTrail_TruncatesUsernames(t *testing.T) {
+
+	username := framework.RandomString(200)
+	failLogins(t, username, randomClientIP(), 1)
+
+	list_resp, _ := AuthorizationApi.ListLoginAttempts(token, username[:64])
+	assert.Equal(t, 200, list_resp.StatusCode)
+	attempts, _ := framework.ParseResponseBody[models.ListLoginAttempts](list_resp.Body)
+	if assert.Len(t, attempts.Items, 1) {
+		assert.Equal(t, username[:64], attempts.Items[0].Username)
+	}
+}
@@ -0,0 +1,179 @@
+package e2etests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// idpClaims are the claims of a valid token of the stand-in identity provider for a user in the given groups
+func idpClaims(groups ...string) jwt.MapClaims {
+	trusted := Configuration.TrustedIssuers[0]
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                 trusted.Issuer,
+		"aud":                 trusted.Audience,
+		"sub":                 framework.GetRandomName("idp-user"),
+		trusted.UsernameClaim: framework.GetRandomName("user") + "@example.com",
+		trusted.RolesClaim:    groups,
+		"iat":                 now.Unix(),
+		"nbf":                 now.Unix(),
+		"exp":                 now.Add(time.Minute).Unix(),
+	}
+}
+
+// signIdPToken signs claims with the current key of the stand-in identity provider
+func signIdPToken(t *testing.T, claims jwt.MapClaims) string {
+	if IdP == nil {
+		t.Fatal("the identity provider is not running")
+	}
+	signed, err := IdP.Sign(claims)
+	assert.NoError(t, err)
+	return signed
+}
+
+/*
+A token of a trusted issuer is accepted with the roles mapped from its groups
+*/
+func TestOidc_TrustedIssuer_RolesAreMapped(t *testing.T) {
+
+	readerApi := service.NewServiceApi(Client, baseUrl, signIdPToken(t, idpClaims("catalog-readers")))
+	list_resp, _ := readerApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	create_resp, _ := readerApi.CreateService(framework.CreateServicePayload(framework.GetRandomName("service"), framework.GetRandomName("service"), "idp"))
+	assert.Equal(t, 403, create_resp.StatusCode)
+
+	editorApi := service.NewServiceApi(Client, baseUrl, signIdPToken(t, idpClaims("Catalog-Editors", "unrelated-group")))
+	create_resp, _ = editorApi.CreateService(framework.CreateServicePayload(framework.GetRandomName("service"), framework.GetRandomName("service"), "idp"))
+	assert.Equal(t, 201, create_resp.StatusCode)
+}
+
+/*
+A token of a trusted issuer without any mapped group is authenticated but not allowed anything
+*/
+func TestOidc_UnmappedGroups_AreForbidden(t *testing.T) {
+
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", signIdPToken(t, idpClaims("marketing")))
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+/*
+Tokens of a trusted issuer are validated like the tokens of the server
+*/
+func TestOidc_InvalidTokens_AreRejected(t *testing.T) {
+
+	trusted := Configuration.TrustedIssuers[0]
+	testCases := []struct {
+		name  string
+		claim string
+		value interface{}
+		code  string
+	}{
+		{"audience", "aud", "another-service", "invalid_token_audience"},
+		{"expired", "exp", time.Now().Add(-time.Hour).Unix(), "token_expired"},
+		{"not before", "nbf", time.Now().Add(time.Hour).Unix(), "token_not_yet_valid"},
+		{"missing username", trusted.UsernameClaim, nil, "invalid_token_subject"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := idpClaims("catalog-readers")
+			if tc.value == nil {
+				delete(claims, tc.claim)
+			} else {
+				claims[tc.claim] = tc.value
+			}
+			resp, _ := Client.HttpGet(baseUrl+"/v1/services", signIdPToken(t, claims))
+			assert.Equal(t, 401, resp.StatusCode)
+			assert.Equal(t, tc.code, extractErrorResponse(*resp).Error.Code)
+		})
+	}
+}
+
+/*
+A token claiming a trusted issuer but signed with a key the issuer does not publish is rejected
+*/
+func TestOidc_UnpublishedKey_IsRejected(t *testing.T) {
+
+	forged, err := IdP.SignWithUnpublishedKey(idpClaims("catalog-admins"))
+	assert.NoError(t, err)
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", forged)
+	assert.Equal(t, 401, resp.StatusCode)
+	assert.Equal(t, "invalid_token", extractErrorResponse(*resp).Error.Code)
+}
+
+/*
+The identity provider rotates its key; the keys are fetched again and tokens signed with the new key are accepted
+*/
+func TestOidc_KeyRotation_RefreshesKeys(t *testing.T) {
+
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", signIdPToken(t, idpClaims("catalog-readers")))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.NoError(t, IdP.RotateKey())
+	// The keys are fetched again at most once per second
+	time.Sleep(1100 * time.Millisecond)
+	resp, _ = Client.HttpGet(baseUrl+"/v1/services", signIdPToken(t, idpClaims("catalog-readers")))
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+/*
+Users of a trusted issuer cannot create API keys, since keys act with the role of a user of the catalog
+*/
+func TestOidc_ExternalUser_CannotCreateApiKey(t *testing.T) {
+
+	keyApi := service.NewApiKeyApi(Client, baseUrl, signIdPToken(t, idpClaims("catalog-admins")))
+	resp, _ := keyApi.CreateApiKey(models.NewApiKey{Name: "ci"})
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+/*
+A user of a trusted issuer named like a local user does not own the API keys and tokens of the local user
+*/
+func TestOidc_ExternalUser_IsNotTheLocalUserOfTheSameName(t *testing.T) {
+
+	local, password := CreateUser("editor")
+	localKeys := service.NewApiKeyApi(Client, baseUrl, login(t, local.Username, password).Token)
+	key_resp, _ := localKeys.CreateApiKey(models.NewApiKey{Name: "local"})
+	assert.Equal(t, 201, key_resp.StatusCode)
+	key := extractApiKeyResponse(key_resp)
+	localTokens := login(t, local.Username, password)
+
+	claims := idpClaims("catalog-editors")
+	claims[Configuration.TrustedIssuers[0].UsernameClaim] = local.Username
+	external := signIdPToken(t, claims)
+	externalKeys := service.NewApiKeyApi(Client, baseUrl, external)
+	list_resp, _ := externalKeys.ListApiKeys(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	assert.Empty(t, extractListApiKeysResponse(list_resp).Items)
+	revoke_resp, _ := externalKeys.RevokeApiKey(key.Item.ID)
+	assert.Equal(t, 404, revoke_resp.StatusCode)
+	revoke_resp, _ = AuthorizationApi.RevokeToken(external, server.RevokeRequest{Token: localTokens.RefreshToken})
+	assert.Equal(t, 403, revoke_resp.StatusCode)
+
+	// Its changes are recorded under its issuer
+	create_resp, _ := service.NewServiceApi(Client, baseUrl, external).CreateService(
+		framework.CreateServicePayload("", framework.GetRandomName("service"), "idp"))
+	assert.Equal(t, 201, create_resp.StatusCode)
+	events := listAuditEvents(t, map[string]string{"resource_id": extractServiceResponse(create_resp).Item.ID})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, Configuration.TrustedIssuers[0].Issuer+"|"+local.Username, events[0].Actor)
+	}
+}
+
+/*
+Tokens of a trusted issuer without nbf and iat claims are accepted
+*/
+func TestOidc_TokenWithoutNbfAndIat_IsAccepted(t *testing.T) {
+
+	claims := idpClaims("catalog-readers")
+	delete(claims, "nbf")
+	delete(claims, "iat")
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", signIdPToken(t, claims))
+	assert.Equal(t, 200, resp.StatusCode)
+}
@@ -0,0 +1,127 @@
+package e2etests
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/stretchr/testify/assert"
+)
+
+// throttledLoginsMetric matches the number of logins rejected by the rate limiter in the metrics
+var throttledLoginsMetric = regexp.MustCompile(`http_requests_throttled_total\{route="POST /v1/token",limit="client"\} (\d+)`)
+
+// sendInvalidLogin sends a login without credentials, rejected by the request validation without checking any password
+func sendInvalidLogin(clientIP string) http.Response {
+	headers := map[string]string{"Content-Type": "application/json", "X-Forwarded-For": clientIP}
+	resp, _ := Client.HttpDo(http.MethodPost, baseUrl+"/v1/token", headers, strings.NewReader("{}"))
+	return *resp
+}
+
+// exhaustLoginRateLimit sends logins from the client until it is throttled and returns the throttled response, along
+// with the number of logins allowed before
+func exhaustLoginRateLimit(t *testing.T, clientIP string) (http.Response, int) {
+	burst := Configuration.RateLimiting.Routes[0].Burst
+	for allowed := 0; allowed <= 2*burst; allowed++ {
+		resp := sendInvalidLogin(clientIP)
+		if resp.StatusCode == 429 {
+			return resp, allowed
+		}
+		assert.Equal(t, 400, resp.StatusCode)
+	}
+	t.Fatalf("logins from %v were never throttled", clientIP)
+	return http.Response{}, 0
+}
+
+// throttledLogins returns the number of logins rejected by the rate limiter so far
+func throttledLogins(t *testing.T) int {
+	resp, _ := Client.HttpGet(baseUrl+"/metrics", "")
+	assert.Equal(t, 200, resp.StatusCode)
+	match := throttledLoginsMetric.FindStringSubmatch(framework.ResponseBodyToString(*resp))
+	if match == nil {
+		return 0
+	}
+	count, err := strconv.Atoi(match[1])
+	assert.NoError(t, err)
+	return count
+}
+
+/*
+Every response reports the state of the rate limit of the client
+*/
+func TestRateLimit_HeadersAreReported(t *testing.T) {
+
+	resp, _ := Client.HttpGet(baseUrl+"/v1/services", token)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, strconv.Itoa(Configuration.RateLimiting.PerClient.Burst), resp.Header.Get("RateLimit-Limit"))
+	remaining, err := strconv.Atoi(resp.Header.Get("RateLimit-Remaining"))
+	assert.NoError(t, err)
+	assert.Less(t, remaining, Configuration.RateLimiting.PerClient.Burst)
+	assert.NotEmpty(t, resp.Header.Get("RateLimit-Reset"))
+}
+
+/*
+A route with its own limit throttles a client once its burst is used, without throttling other clients
+*/
+func TestRateLimit_RouteOverride_ThrottlesClient(t *testing.T) {
+
+	route := Configuration.RateLimiting.Routes[0]
+	assert.Equal(t, "/v1/token", route.Path)
+
+	resp, allowed := exhaustLoginRateLimit(t, randomClientIP())
+	assert.GreaterOrEqual(t, allowed, route.Burst)
+	assert.Equal(t, "rate_limited", extractErrorResponse(resp).Error.Code)
+	assert.Equal(t, strconv.Itoa(route.Burst), resp.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, retryAfter, 1)
+
+	// Other clients have their own bucket
+	assert.Equal(t, 400, sendInvalidLogin(randomClientIP()).StatusCode)
+}
+
+/*
+Throttled requests are counted in the metrics, by route
+*/
+func TestRateLimit_ThrottledRequests_AreCounted(t *testing.T) {
+
+	before := throttledLogins(t)
+	exhaustLoginRateLimit(t, randomClientIP())
+	assert.Equal(t, before+1, throttledLogins(t))
+}
+
+// sendInvalidToken lists the services from the client with a token that fails authentication
+func sendInvalidToken(clientIP string) http.Response {
+	headers := map[string]string{"Authorization": "Bearer " + framework.RandomString(32), "X-Forwarded-For": clientIP}
+	resp, _ := Client.HttpDo(http.MethodGet, baseUrl+"/v1/services", headers, nil)
+	return *resp
+}
+
+/*
+Requests failing authentication are limited per client IP, before they are authenticated
+*/
+func TestRateLimit_InvalidTokens_AreThrottledPerIP(t *testing.T) {
+
+	clientIP := randomClientIP()
+	burst := Configuration.RateLimiting.PerIP.Burst
+	for allowed := 0; ; allowed++ {
+		resp := sendInvalidToken(clientIP)
+		if resp.StatusCode == 429 {
+			assert.GreaterOrEqual(t, allowed, burst)
+			assert.Equal(t, "rate_limited", extractErrorResponse(resp).Error.Code)
+			assert.Equal(t, strconv.Itoa(burst), resp.Header.Get("RateLimit-Limit"))
+			break
+		}
+		assert.Equal(t, 401, resp.StatusCode)
+		if allowed > 4*burst {
+			t.Fatalf("invalid tokens from %v were never throttled", clientIP)
+		}
+	}
+
+	// Other clients have their own bucket
+	assert.Equal(t, 401, sendInvalidToken(randomClientIP()).StatusCode)
+}
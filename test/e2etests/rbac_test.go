@@ -0,0 +1,147 @@
+package e2etests
+
+import (
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// apisForRole creates a user with the given role and returns the service and
+// service version apis authenticated with its token.
+func apisForRole(role string) (*service.ServiceApi, *service.ServiceVersionApi, string) {
+	user, password := CreateUser(role)
+	userToken := AuthorizationApi.FetchToken(user.Username, password)
+	return service.NewServiceApi(Client, baseUrl, userToken), service.NewServiceVersionApi(Client, baseUrl, userToken), userToken
+}
+
+/*
+Request a token for a user and verify it carries the role of the user
+*/
+func TestRbac_Token_CarriesRoleClaim(t *testing.T) {
+
+	_, _, viewerToken := apisForRole("viewer")
+	hasRole, _ := framework.TokenHasRoleClaim(viewerToken, "viewer")
+	assert.True(t, hasRole)
+
+	hasRole, _ = framework.TokenHasRoleClaim(token, "admin")
+	assert.True(t, hasRole)
+}
+
+/*
+Read the catalog with a viewer token and expect every read to succeed
+*/
+func TestRbac_Viewer_CanReadCatalog(t *testing.T) {
+
+	serviceVersion := CreateServiceVersion_Success()
+	serviceApi, versionApi, viewerToken := apisForRole("viewer")
+
+	list_resp, _ := serviceApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	get_resp, _ := serviceApi.GetService(serviceVersion.Item.ServiceID)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	versions_resp, _ := versionApi.ListServiceVersions(serviceVersion.Item.ServiceID, models.ListOptions{})
+	assert.Equal(t, 200, versions_resp.StatusCode)
+	search_resp, _ := service.NewSearchApi(Client, baseUrl, viewerToken).Search("service", models.ListOptions{})
+	assert.Equal(t, 200, search_resp.StatusCode)
+}
+
+/*
+Change the catalog with a viewer token and verify
+1. Every POST, PATCH and DELETE is Forbidden
+2. The catalog is left unchanged
+*/
+func TestRbac_Viewer_CannotChangeCatalog(t *testing.T) {
+
+	serviceVersion := CreateServiceVersion_Success()
+	serviceId := serviceVersion.Item.ServiceID
+	versionId := serviceVersion.Item.ID
+	serviceApi, versionApi, _ := apisForRole("viewer")
+
+	serviceName := framework.GetRandomName("service")
+	create_resp, _ := serviceApi.CreateService(framework.CreateServicePayload(serviceName, serviceName, ""))
+	assert.Equal(t, 403, create_resp.StatusCode)
+	assert.Equal(t, "forbidden", extractErrorResponse(create_resp).Error.Code)
+
+	update_resp, _ := serviceApi.UpdateService(serviceId, models.Service{Name: "renamed"})
+	assert.Equal(t, 403, update_resp.StatusCode)
+
+	delete_resp, _ := serviceApi.DeleteService(serviceId)
+	assert.Equal(t, 403, delete_resp.StatusCode)
+
+	create_version_resp, _ := versionApi.CreateServiceVersion(serviceId, framework.CreateServiceVersionPayload(serviceId, "", ""))
+	assert.Equal(t, 403, create_version_resp.StatusCode)
+
+	update_version_resp, _ := versionApi.UpdateServiceVersion(serviceId, versionId, models.ServiceVersion{Version: "v9"})
+	assert.Equal(t, 403, update_version_resp.StatusCode)
+
+	delete_version_resp, _ := versionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 403, delete_version_resp.StatusCode)
+
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.NotEqual(t, "renamed", extractServiceResponse(get_resp).Item.Name)
+	get_version_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, versionId)
+	assert.Equal(t, 200, get_version_resp.StatusCode)
+}
+
+/*
+Change the catalog with an editor token and expect the changes to succeed
+*/
+func TestRbac_Editor_CanChangeCatalog(t *testing.T) {
+
+	serviceApi, _, _ := apisForRole("editor")
+
+	serviceName := framework.GetRandomName("service")
+	create_resp, _ := serviceApi.CreateService(framework.CreateServicePayload(serviceName, serviceName, ""))
+	assert.Equal(t, 201, create_resp.StatusCode)
+	serviceId := extractServiceResponse(create_resp).Item.ID
+
+	update_resp, _ := serviceApi.UpdateService(serviceId, models.Service{Name: "renamed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	delete_resp, _ := serviceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+}
+
+/*
+Promote a viewer to editor and verify the tokens it requests afterwards can change the catalog
+*/
+func TestRbac_PromoteViewer_ToEditor(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	editor := "editor"
+	update_resp, _ := UserApi.UpdateUser(user.ID, models.UserUpdate{Role: &editor})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.Equal(t, "editor", extractUserResponse(update_resp).Item.Role)
+
+	serviceApi := service.NewServiceApi(Client, baseUrl, AuthorizationApi.FetchToken(user.Username, password))
+	serviceName := framework.GetRandomName("service")
+	create_resp, _ := serviceApi.CreateService(framework.CreateServicePayload(serviceName, serviceName, ""))
+	assert.Equal(t, 201, create_resp.StatusCode)
+}
+
+/*
+Demote an editor to viewer and verify the token it requested before the demotion can no longer change the catalog
+*/
+func TestRbac_DemoteEditor_TakesEffectImmediately(t *testing.T) {
+
+	user, password := CreateUser("editor")
+	serviceApi := service.NewServiceApi(Client, baseUrl, AuthorizationApi.FetchToken(user.Username, password))
+	serviceName := framework.GetRandomName("service")
+	create_resp, _ := serviceApi.CreateService(framework.CreateServicePayload(serviceName, serviceName, ""))
+	assert.Equal(t, 201, create_resp.StatusCode)
+
+	viewer := "viewer"
+	update_resp, _ := UserApi.UpdateUser(user.ID, models.UserUpdate{Role: &viewer})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	serviceName = framework.GetRandomName("service")
+	create_resp, _ = serviceApi.CreateService(framework.CreateServicePayload(serviceName, serviceName, ""))
+	assert.Equal(t, 403, create_resp.StatusCode)
+	assert.Equal(t, "forbidden", extractErrorResponse(create_resp).Error.Code)
+	list_resp, _ := serviceApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+}
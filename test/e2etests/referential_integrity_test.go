@@ -0,0 +1,108 @@
+package e2etests
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Versions cannot be created for an unknown service
+*/
+func TestReferentialIntegrity_CreateVersion_UnknownService(t *testing.T) {
+
+	serviceId := uuid.NewString()
+	resp, _ := ServiceVersionApi.CreateServiceVersion(serviceId, framework.CreateServiceVersionPayload(serviceId, "", "v1.0.0"))
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(resp).Error.Code)
+}
+
+/*
+Versions cannot be created for a deleted service
+*/
+func TestReferentialIntegrity_CreateVersion_DeletedService(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	resp, _ := ServiceVersionApi.CreateServiceVersion(serviceId, framework.CreateServiceVersionPayload(serviceId, "", "v1.0.0"))
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(resp).Error.Code)
+}
+
+/*
+A service with versions is not deleted without cascade, so its versions are not left behind
+*/
+func TestReferentialIntegrity_DeleteService_WithVersions_Conflict(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 409, resp.StatusCode)
+	assert.Equal(t, "service_has_versions", extractErrorResponse(resp).Error.Code)
+
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Len(t, listServiceVersionsAndExtractTheList(serviceId).Items, 1)
+
+	// Once its versions are deleted, the service can be deleted as well
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	resp, _ = ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, resp.StatusCode)
+}
+
+/*
+Deleting a service with cascade deletes its versions, which are restored along with it
+*/
+func TestReferentialIntegrity_DeleteService_Cascade(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0", "v2.0.0", "v3.0.0")
+	versions := listServiceVersionsAndExtractTheList(serviceId)
+
+	// A version deleted on its own stays deleted when the service is restored
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versions.Items[0].ID)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	resp, _ := ServiceApi.DeleteServiceCascade(serviceId)
+	assert.Equal(t, 204, resp.StatusCode)
+	assert.Empty(t, listServiceVersionsAndExtractTheList(serviceId).Items)
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, versions.Items[1].ID)
+	assert.Equal(t, 404, get_resp.StatusCode)
+
+	// Versions of a deleted service are only restored along with it
+	restore_resp, _ := ServiceVersionApi.RestoreServiceVersion(serviceId, versions.Items[1].ID)
+	assert.Equal(t, 404, restore_resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(restore_resp).Error.Code)
+
+	restore_resp, _ = ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+	restored := listServiceVersionsAndExtractTheList(serviceId)
+	if assert.Len(t, restored.Items, 2) {
+		assert.Equal(t, versions.Items[1].ID, restored.Items[0].ID)
+		assert.Equal(t, versions.Items[2].ID, restored.Items[1].ID)
+	}
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{IncludeDeleted: true})
+	assert.Equal(t, 3, extractListServiceVersionsResponse(list_resp).Pagination.Total)
+}
+
+/*
+An invalid cascade value is rejected rather than read as false
+*/
+func TestReferentialIntegrity_DeleteService_InvalidCascade(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	resp, _ := ServiceApi.DeleteServiceWithCascade(serviceId, "yes")
+	assert.Equal(t, 400, resp.StatusCode)
+	error_resp := extractErrorResponse(resp)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, "cascade", error_resp.Error.Details[0].Field)
+
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Len(t, listServiceVersionsAndExtractTheList(serviceId).Items, 1)
+}
@@ -0,0 +1,92 @@
+package e2etests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Create a service with a name longer than the 64 characters allowed by the OpenAPI specification
+The request has to be rejected with a 400 naming the offending field, instead of reaching the database
+*/
+func TestRequestValidation_CreateService_NameTooLong(t *testing.T) {
+
+	payload := framework.CreateServicePayload(framework.GetRandomName("service"), framework.RandomString(65), "test service")
+	service_response, _ := CreateService(payload)
+	assert.NotEqual(t, 500, service_response.StatusCode)
+	assert.Equal(t, 400, service_response.StatusCode)
+	error_resp := extractErrorResponse(service_response)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, []models.FieldError{{Field: "name", Message: "maximum string length is 64"}}, error_resp.Error.Details)
+
+	get_response, _ := ServiceApi.GetService(payload.ID)
+	assert.Equal(t, 404, get_response.StatusCode)
+}
+
+/*
+Create a service without the required id and name fields and expect a violation for each of them
+*/
+func TestRequestValidation_CreateService_MissingRequiredFields(t *testing.T) {
+
+	url := fmt.Sprintf("%s/v1/services", baseUrl)
+	service_response, _ := Client.HttpPost(url, token, strings.NewReader(`{"description":"test service"}`))
+	assert.Equal(t, 400, service_response.StatusCode)
+	error_resp := extractErrorResponse(*service_response)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.ElementsMatch(t, []models.FieldError{
+		{Field: "id", Message: `property "id" is missing`},
+		{Field: "name", Message: `property "name" is missing`},
+	}, error_resp.Error.Details)
+}
+
+/*
+Create a service with a body that is not JSON and expect the violation to be reported against the body
+*/
+func TestRequestValidation_CreateService_MalformedBody(t *testing.T) {
+
+	url := fmt.Sprintf("%s/v1/services", baseUrl)
+	service_response, _ := Client.HttpPost(url, token, strings.NewReader(`{"id":`))
+	assert.Equal(t, 400, service_response.StatusCode)
+	error_resp := extractErrorResponse(*service_response)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, 1, len(error_resp.Error.Details))
+	assert.Equal(t, "body", error_resp.Error.Details[0].Field)
+}
+
+/*
+List services with query parameters of the wrong type and expect a violation for each of them
+*/
+func TestRequestValidation_ListServices_InvalidQueryParameters(t *testing.T) {
+
+	url := fmt.Sprintf("%s/v1/services?page=first&limit=ten", baseUrl)
+	list_response, _ := Client.HttpGet(url, token)
+	assert.Equal(t, 400, list_response.StatusCode)
+	error_resp := extractErrorResponse(*list_response)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	fields := []string{}
+	for _, detail := range error_resp.Error.Details {
+		fields = append(fields, detail.Field)
+	}
+	assert.ElementsMatch(t, []string{"page", "limit"}, fields)
+}
+
+/*
+Partially update a service with only its description; the fields required on creation are optional here
+*/
+func TestRequestValidation_UpdateService_PartialPayload(t *testing.T) {
+
+	service_object := CreateService_Success()
+	serviceId := service_object.Item.ID
+
+	url := fmt.Sprintf("%s/v1/services/%s", baseUrl, serviceId)
+	update_response, _ := Client.HttpPatch(url, token, strings.NewReader(`{"description":"updated description"}`))
+	assert.Equal(t, 200, update_response.StatusCode)
+	service := extractServiceResponse(*update_response)
+	assert.Equal(t, service_object.Item.Name, service.Item.Name)
+	assert.Equal(t, "updated description", service.Item.Description)
+}
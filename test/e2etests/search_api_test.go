@@ -0,0 +1,117 @@
+package e2etests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// createServiceWithNameAndDescription creates a service and returns its ID.
+func createServiceWithNameAndDescription(name string, description string) string {
+	payload := framework.CreateServicePayload("", name, description)
+	service_resp, _ := CreateService(payload)
+	return extractServiceResponse(service_resp).Item.ID
+}
+
+/*
+Search services by a fragment of their name through the list endpoint
+GET v1/services?q=
+*/
+func TestSearchApi_ListServices_MatchesNamePrefix(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(10))
+	serviceId := createServiceWithNameAndDescription("Ledger "+keyword, "test service")
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: "ledger " + keyword[:5]})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	services := extractListServicesResponse(list_resp)
+	assert.Equal(t, 1, services.Pagination.Total)
+	serviceExists, _ := serviceWithIDExists(services, serviceId)
+	assert.True(t, serviceExists)
+}
+
+/*
+Search services where one matches on the name and the other on the description
+The name match is more relevant and has to be listed first
+*/
+func TestSearchApi_ListServices_RanksNameAboveDescription(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(10))
+	descriptionMatchId := createServiceWithNameAndDescription("Reporting", "Exports "+keyword+" reports")
+	nameMatchId := createServiceWithNameAndDescription("Reporting "+keyword, "Exports reports")
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: keyword})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	services := extractListServicesResponse(list_resp)
+	assert.Equal(t, 2, len(services.Items))
+	assert.Equal(t, nameMatchId, services.Items[0].ID)
+	assert.Equal(t, descriptionMatchId, services.Items[1].ID)
+}
+
+/*
+Search services after an update and a delete to verify the index is kept in sync
+*/
+func TestSearchApi_ListServices_IndexFollowsUpdatesAndDeletes(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(10))
+	renamedKeyword := strings.ToLower(framework.RandomString(10))
+	serviceId := createServiceWithNameAndDescription("Billing "+keyword, "test service")
+
+	ServiceApi.UpdateService(serviceId, models.Service{Name: "Billing " + renamedKeyword})
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: keyword})
+	assert.Empty(t, extractListServicesResponse(list_resp).Items)
+	list_resp, _ = ServiceApi.ListServices(models.ListOptions{Query: renamedKeyword})
+	assert.Equal(t, 1, len(extractListServicesResponse(list_resp).Items))
+
+	ServiceApi.DeleteService(serviceId)
+	list_resp, _ = ServiceApi.ListServices(models.ListOptions{Query: renamedKeyword})
+	assert.Empty(t, extractListServicesResponse(list_resp).Items)
+}
+
+/*
+Search the catalog for a term that matches both a service and a version of another service
+GET v1/search?q=
+*/
+func TestSearchApi_Search_SpansServicesAndVersions(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(8))
+	serviceId := createServiceWithNameAndDescription("Payments "+keyword, "test service")
+	versionServiceId := CreateServiceWithVersions("v1-" + keyword)
+
+	search_resp, _ := SearchApi.Search(keyword, models.ListOptions{})
+	assert.Equal(t, 200, search_resp.StatusCode)
+	results := extractSearchResponse(search_resp)
+	assert.Equal(t, 2, results.Pagination.Total)
+
+	found := map[string]models.SearchResult{}
+	for _, result := range results.Items {
+		found[result.Type] = result
+	}
+	assert.Equal(t, serviceId, found["service"].ID)
+	assert.Equal(t, "Payments <mark>"+keyword+"</mark>", found["service"].Highlights["name"])
+	assert.Equal(t, versionServiceId, found["service_version"].ServiceID)
+	assert.Equal(t, "v1-"+keyword, found["service_version"].Version)
+	assert.Equal(t, "v1-<mark>"+keyword+"</mark>", found["service_version"].Highlights["version"])
+}
+
+/*
+Search the catalog without a query or without search terms and expect a Bad Request
+*/
+func TestSearchApi_Search_InvalidQuery(t *testing.T) {
+
+	search_resp, _ := SearchApi.Search("", models.ListOptions{})
+	assert.Equal(t, 400, search_resp.StatusCode)
+	error_resp := extractErrorResponse(search_resp)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, "q", error_resp.Error.Details[0].Field)
+
+	for _, query := range []string{"***", " - "} {
+		search_resp, _ := SearchApi.Search(query, models.ListOptions{})
+		assert.Equal(t, 400, search_resp.StatusCode)
+		error_resp := extractErrorResponse(search_resp)
+		assert.Equal(t, "invalid_search_query", error_resp.Error.Code)
+	}
+}
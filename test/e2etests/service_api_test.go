@@ -68,12 +68,10 @@ func TestServiceApi_CreateServiceAndVerifyListServices(t *testing.T) {
 	serviceId := service_object.Item.ID
 	assert.NotNil(t, serviceId)
 
-	//Get Service by Id and check the same verifications
-	get_resp, _ := ServiceApi.ListServices()
-	list_services := extractListServicesResponse(get_resp)
+	//List Services across all pages and check the same verifications
+	list_services := listServicesAndExtractTheList()
 	serviceExistsInListServices, targetService := serviceWithIDExists(list_services, serviceId)
 	assert.True(t, serviceExistsInListServices)
-	assert.Equal(t, 200, get_resp.StatusCode)
 	assert.Equal(t, serviceName, targetService.Name)
 	assert.NotEmpty(t, targetService.CreatedAt)
 	assert.Equal(t, serviceId, targetService.ID)
@@ -104,20 +102,23 @@ func TestServiceApi_ServiceCreationFailsWithEmptyId(t *testing.T) {
 	assert.NotEqual(t, 201, service_response.StatusCode)
 }
 
-// Invoke Get Service with non existent or invalid service ID and expect a 200/404??
+// Invoke Get Service with non existent or invalid service ID and expect a 404
 func TestServiceApi_GetServiceWithNonExistentId(t *testing.T) {
 	serviceId := "invalid"
 	service_response, _ := ServiceApi.GetService(serviceId)
-	assert.Equal(t, 200, service_response.StatusCode)
-	assert.True(t, service_response.ContentLength == 0)
+	assert.Equal(t, 404, service_response.StatusCode)
+	assert.Equal(t, "application/json", service_response.Header.Get("Content-Type"))
+	error_resp := extractErrorResponse(service_response)
+	assert.Equal(t, "service_not_found", error_resp.Error.Code)
 }
 
-// Invoke Get Service with non existent or invalid service ID and expect a 200/404??
+// Invoke Get Service with an empty service ID and expect a 404
 func TestServiceApi_GetServiceWithEmptyId(t *testing.T) {
 	serviceId := " "
 	service_response, _ := ServiceApi.GetService(serviceId)
-	assert.Equal(t, 200, service_response.StatusCode)
-	assert.True(t, service_response.ContentLength == 0)
+	assert.Equal(t, 404, service_response.StatusCode)
+	error_resp := extractErrorResponse(service_response)
+	assert.Equal(t, "service_not_found", error_resp.Error.Code)
 
 }
 
@@ -126,7 +127,7 @@ Delete the service and
 1. Verify the delete api response
 2. Verify empty response body
 3. List Services and verify the count decremented with the deleted item
-4. Get Deleted Service and verify empty response
+4. Get Deleted Service and verify a 404 response
 */
 func TestServiceApi_DeleteService(t *testing.T) {
 
@@ -140,15 +141,13 @@ func TestServiceApi_DeleteService(t *testing.T) {
 	assert.Equal(t, 204, delete_response.StatusCode)
 	assert.True(t, delete_response.ContentLength == 0)
 
-	//Call GET /services/{serviceId} to see we get an empty response
-	//Get Service by Id and check the same verifications
+	//Call GET /services/{serviceId} to see we get a not found error
 	get_resp, _ := ServiceApi.GetService(serviceId)
-	service_object = extractServiceResponse(get_resp)
-	assert.Equal(t, 200, get_resp.StatusCode)
-	assert.True(t, get_resp.ContentLength == 0)
+	assert.Equal(t, 404, get_resp.StatusCode)
+	error_resp := extractErrorResponse(get_resp)
+	assert.Equal(t, "service_not_found", error_resp.Error.Code)
 
 	//Cal GET /services to see the count decrements by 1
-	serviceId = service_object.Item.ID
 	services = listServicesAndExtractTheList()
 	serviceCountAfterDelete := len(services.Items)
 	assert.True(t, serviceCountAfterDelete == serviceCountBeforeDelete-1)
@@ -239,3 +238,75 @@ func TestServiceApi_UpdateService(t *testing.T) {
 	assert.Equal(t, updatedserviceName, service.Name)
 	assert.Equal(t, updatedDescription, service.Description)
 }
+
+/*
+List Services with the default pagination and verify the pagination metadata
+GET v1/services
+*/
+func TestServiceApi_ListServices_DefaultPagination(t *testing.T) {
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	services := extractListServicesResponse(list_resp)
+	assert.Equal(t, 1, services.Pagination.Page)
+	assert.Equal(t, 10, services.Pagination.Limit)
+	assert.True(t, len(services.Items) <= 10)
+	assert.Nil(t, services.Pagination.PrevPage)
+	assert.Equal(t, (services.Pagination.Total+9)/10, services.Pagination.TotalPages)
+}
+
+/*
+Walk every page of List Services with a small limit and verify
+1. Every page except the last is full and links to the next page
+2. No service is returned twice
+3. The number of services collected matches the reported total
+*/
+func TestServiceApi_ListServices_WalkPages(t *testing.T) {
+
+	CreateService_Success()
+	limit := 3
+	seen := map[string]bool{}
+	opts := models.ListOptions{Page: 1, Limit: limit}
+	var total int
+	for {
+		list_resp, _ := ServiceApi.ListServices(opts)
+		assert.Equal(t, 200, list_resp.StatusCode)
+		services := extractListServicesResponse(list_resp)
+		assert.Equal(t, opts.Page, services.Pagination.Page)
+		total = services.Pagination.Total
+		for _, service := range services.Items {
+			assert.False(t, seen[service.ID], "service %v returned on more than one page", service.ID)
+			seen[service.ID] = true
+		}
+		if services.Pagination.NextPage == nil {
+			break
+		}
+		assert.Equal(t, limit, len(services.Items))
+		assert.Equal(t, opts.Page+1, *services.Pagination.NextPage)
+		opts.Page = *services.Pagination.NextPage
+	}
+	assert.Equal(t, total, len(seen))
+}
+
+/*
+List Services with out of range page and limit values and expect a Bad Request
+*/
+func TestServiceApi_ListServices_InvalidPaginationParameters(t *testing.T) {
+
+	invalidOptions := []struct {
+		field string
+		opts  models.ListOptions
+	}{
+		{"page", models.ListOptions{Page: -1}},
+		{"limit", models.ListOptions{Limit: -5}},
+		{"limit", models.ListOptions{Limit: 101}},
+	}
+	for _, invalid := range invalidOptions {
+		list_resp, _ := ServiceApi.ListServices(invalid.opts)
+		assert.Equal(t, 400, list_resp.StatusCode)
+		error_resp := extractErrorResponse(list_resp)
+		assert.Equal(t, "validation_failed", error_resp.Error.Code)
+		assert.Equal(t, 1, len(error_resp.Error.Details))
+		assert.Equal(t, invalid.field, error_resp.Error.Details[0].Field)
+	}
+}
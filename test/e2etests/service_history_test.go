@@ -0,0 +1,208 @@
+package e2etests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// serviceHistory returns the first page of the history of a service, most recent revisions first
+func serviceHistory(t *testing.T, serviceId string) models.ListServiceHistory {
+	history_resp, _ := ServiceApi.GetServiceHistory(serviceId, models.ListOptions{Limit: 100})
+	assert.Equal(t, 200, history_resp.StatusCode)
+	return extractServiceHistoryResponse(history_resp)
+}
+
+/*
+The history lists the revisions of a service and of its versions, with the fields each one changed
+*/
+func TestServiceHistory_ListsRevisionsWithDiffs(t *testing.T) {
+
+	created := CreateService_Success().Item
+	update_resp, _ := ServiceApi.UpdateService(created.ID, models.Service{Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	serviceId := created.ID
+	version_resp, _ := ServiceVersionApi.CreateServiceVersion(serviceId,
+		framework.CreateServiceVersionPayload(serviceId, "", "v1.0.0"))
+	assert.Equal(t, 201, version_resp.StatusCode)
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	update_version_resp, _ := ServiceVersionApi.UpdateServiceVersion(serviceId, versionId, models.ServiceVersion{Version: "v1.1.0"})
+	assert.Equal(t, 200, update_version_resp.StatusCode)
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	history := serviceHistory(t, serviceId)
+	assert.Equal(t, 5, history.Pagination.Total)
+	if !assert.Len(t, history.Items, 5) {
+		return
+	}
+
+	deleted := history.Items[0]
+	assert.Equal(t, "service_version", deleted.ResourceType)
+	assert.Equal(t, versionId, deleted.ResourceID)
+	assert.Equal(t, int64(3), deleted.Revision)
+	if assert.Len(t, deleted.Changes, 1) {
+		assert.Equal(t, "deleted_at", deleted.Changes[0].Field)
+		assert.Nil(t, deleted.Changes[0].From)
+		assert.NotNil(t, deleted.Changes[0].To)
+	}
+
+	assert.Equal(t, []models.FieldChange{
+		{Field: "version", From: "v1.0.0", To: "v1.1.0"},
+	}, history.Items[1].Changes)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "id", From: nil, To: versionId},
+		{Field: "version", From: nil, To: "v1.0.0"},
+	}, history.Items[2].Changes)
+
+	assert.Equal(t, "service", history.Items[3].ResourceType)
+	assert.Equal(t, int64(2), history.Items[3].Revision)
+	assert.Equal(t, []models.FieldChange{{Field: "description", From: "test service", To: "changed"}},
+		history.Items[3].Changes)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "name", From: nil, To: created.Name},
+		{Field: "description", From: nil, To: "test service"},
+	}, history.Items[4].Changes)
+	assert.False(t, history.Items[0].ChangedAt.Before(history.Items[4].ChangedAt))
+}
+
+/*
+The history is paginated, and the revisions of a page are diffed against their previous revision on another page
+*/
+func TestServiceHistory_Pagination(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	for _, description := range []string{"first", "second", "third"} {
+		update_resp, _ := ServiceApi.UpdateService(serviceId, models.Service{Description: description})
+		assert.Equal(t, 200, update_resp.StatusCode)
+	}
+
+	history_resp, _ := ServiceApi.GetServiceHistory(serviceId, models.ListOptions{Page: 2, Limit: 1})
+	assert.Equal(t, 200, history_resp.StatusCode)
+	history := extractServiceHistoryResponse(history_resp)
+	assert.Equal(t, 4, history.Pagination.Total)
+	assert.Equal(t, 4, history.Pagination.TotalPages)
+	if assert.Len(t, history.Items, 1) {
+		assert.Equal(t, int64(3), history.Items[0].Revision)
+		assert.Equal(t, []models.FieldChange{{Field: "description", From: "first", To: "second"}},
+			history.Items[0].Changes)
+	}
+
+	history_resp, _ = ServiceApi.GetServiceHistory(serviceId, models.ListOptions{Page: 3, Limit: 2})
+	assert.Equal(t, 200, history_resp.StatusCode)
+	assert.Empty(t, extractServiceHistoryResponse(history_resp).Items)
+}
+
+/*
+Reverting a service restores the fields of a prior revision as a new revision
+*/
+func TestServiceHistory_Revert(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	update_resp, _ := ServiceApi.UpdateService(serviceId, models.Service{Name: "renamed", Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	// A stale ETag is rejected
+	revert_resp, _ := ServiceApi.RevertServiceIfMatch(serviceId, 1, `"1"`)
+	assert.Equal(t, 412, revert_resp.StatusCode)
+
+	revert_resp, _ = ServiceApi.RevertServiceIfMatch(serviceId, 1, update_resp.Header.Get("ETag"))
+	assert.Equal(t, 200, revert_resp.StatusCode)
+	assert.Equal(t, `"3"`, revert_resp.Header.Get("ETag"))
+	reverted := extractServiceResponse(revert_resp).Item
+	assert.Equal(t, "test service", reverted.Description)
+	assert.NotEqual(t, "renamed", reverted.Name)
+
+	history := serviceHistory(t, serviceId)
+	if assert.Len(t, history.Items, 3) {
+		assert.Equal(t, int64(3), history.Items[0].Revision)
+		assert.Equal(t, []models.FieldChange{
+			{Field: "name", From: "renamed", To: reverted.Name},
+			{Field: "description", From: "changed", To: "test service"},
+		}, history.Items[0].Changes)
+	}
+
+	events := listAuditEvents(t, map[string]string{"resource_id": serviceId, "action": "revert"})
+	assert.Len(t, events, 1)
+
+	revert_resp, _ = ServiceApi.RevertService(serviceId, 42)
+	assert.Equal(t, 404, revert_resp.StatusCode)
+	assert.Equal(t, "revision_not_found", extractErrorResponse(revert_resp).Error.Code)
+}
+
+/*
+The history of a deleted service is kept, but it must be restored before it is reverted
+*/
+func TestServiceHistory_DeletedAndUnknownServices(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	history := serviceHistory(t, serviceId)
+	if assert.Len(t, history.Items, 2) {
+		assert.Equal(t, "deleted_at", history.Items[0].Changes[0].Field)
+	}
+	revert_resp, _ := ServiceApi.RevertService(serviceId, 1)
+	assert.Equal(t, 404, revert_resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(revert_resp).Error.Code)
+
+	history_resp, _ := ServiceApi.GetServiceHistory(uuid.NewString(), models.ListOptions{})
+	assert.Equal(t, 404, history_resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(history_resp).Error.Code)
+}
+
+/*
+The history of a purged service and of its versions is kept, and is not continued by the services created after the purge
+*/
+func TestServiceHistory_KeptAfterPurge(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	delete_resp, _ := ServiceApi.DeleteServiceCascade(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	before := serviceHistory(t, serviceId)
+
+	// Tombstones are stored with a precision of a second
+	time.Sleep(Configuration.SoftDelete.PurgeAfter + 2*Configuration.SoftDelete.PurgeInterval + time.Second)
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 404, restore_resp.StatusCode)
+
+	history := serviceHistory(t, serviceId)
+	assert.Equal(t, before, history)
+	if assert.Len(t, history.Items, 4) {
+		types := []string{history.Items[0].ResourceType, history.Items[1].ResourceType}
+		assert.ElementsMatch(t, []string{"service", "service_version"}, types)
+		assert.Equal(t, "deleted_at", history.Items[0].Changes[0].Field)
+		assert.Equal(t, "deleted_at", history.Items[1].Changes[0].Field)
+	}
+	revert_resp, _ := ServiceApi.RevertService(serviceId, 1)
+	assert.Equal(t, 404, revert_resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(revert_resp).Error.Code)
+
+	created := CreateService_Success().Item
+	update_resp, _ := ServiceApi.UpdateService(created.ID, models.Service{Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.Len(t, serviceHistory(t, created.ID).Items, 2)
+	assert.Equal(t, history, serviceHistory(t, serviceId))
+}
+
+/*
+Viewers read the history of a service but cannot revert it
+*/
+func TestServiceHistory_ViewerCannotRevert(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	viewer, password := CreateUser("viewer")
+	viewerApi := service.NewServiceApi(Client, baseUrl, login(t, viewer.Username, password).Token)
+
+	history_resp, _ := viewerApi.GetServiceHistory(serviceId, models.ListOptions{})
+	assert.Equal(t, 200, history_resp.StatusCode)
+	revert_resp, _ := viewerApi.RevertService(serviceId, 1)
+	assert.Equal(t, 403, revert_resp.StatusCode)
+}
@@ -3,6 +3,7 @@ package e2etests
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
 	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
@@ -44,7 +45,7 @@ func TestServiceVersionApi_CreateAndListServiceVersions(t *testing.T) {
 	serviceVersion := CreateServiceVersion_Success()
 
 	//List Service version by Id and check the same verifications
-	get_resp, _ := ServiceVersionApi.ListServiceVersions(serviceVersion.Item.ServiceID)
+	get_resp, _ := ServiceVersionApi.ListServiceVersions(serviceVersion.Item.ServiceID, models.ListOptions{})
 	get_service_version_object := extractListServiceVersionsResponse(get_resp)
 	assert.Equal(t, 200, get_resp.StatusCode)
 	assert.Equal(t, len(get_service_version_object.Items), 1)
@@ -82,7 +83,7 @@ func TestServiceVersionApi_CreateServiceVersion_Fails_WithMoreThan17Chars_Versio
 
 /*
 This test aims to see that Create Service version fails with a empty serviceId in the URL
-The Create SErvice version doesnt error out but returns a 200 OK with an empty content
+The empty path segment is redirected to /v1/services/versions which is not a service
 */
 func TestServiceVersionApi_ServiceCreationFails_WithEmptyServiceID_InUrlParameter(t *testing.T) {
 
@@ -91,9 +92,9 @@ func TestServiceVersionApi_ServiceCreationFails_WithEmptyServiceID_InUrlParamete
 	payload := framework.CreateServiceVersionPayload(serviceId, "", "")
 	service_version_resp, _ := ServiceVersionApi.CreateServiceVersion("", payload)
 
-	assert.Equal(t, 200, service_version_resp.StatusCode)
-	service_version_object := extractServiceVersionResponse(service_version_resp)
-	assert.Empty(t, service_version_object.Item)
+	assert.Equal(t, 404, service_version_resp.StatusCode)
+	error_resp := extractErrorResponse(service_version_resp)
+	assert.Equal(t, "service_not_found", error_resp.Error.Code)
 
 }
 
@@ -163,14 +164,14 @@ func TestServiceVersionApi_GetServiceVersion_With_InvalidServiceId_And_VersionId
 	get_resp, _ := ServiceVersionApi.GetServiceVersion(invalidServiceId, versionId)
 	assert.Equal(t, 404, get_resp.StatusCode)
 	error_resp := extractErrorResponse(get_resp)
-	assert.Equal(t, "Service version not found", error_resp.Error)
+	assert.Equal(t, "service_version_not_found", error_resp.Error.Code)
 
 	//Pass invalid versionId, Get Service version by Id and verify the error
 	invalidVersionId := versionId + framework.GetRandomNumber()
 	get_resp, _ = ServiceVersionApi.GetServiceVersion(serviceId, invalidVersionId)
 	assert.Equal(t, 404, get_resp.StatusCode)
 	error_resp = extractErrorResponse(get_resp)
-	assert.Equal(t, "Service version not found", error_resp.Error)
+	assert.Equal(t, "service_version_not_found", error_resp.Error.Code)
 }
 
 /*
@@ -183,7 +184,7 @@ func TestServiceVersionApi_ListServiceVersion_With_InvalidServiceId(t *testing.T
 
 	//Pass invalid serviceId, Get Service version by Id and verify the error
 	invalidServiceId := serviceId + framework.GetRandomNumber()
-	get_resp, _ := ServiceVersionApi.ListServiceVersions(invalidServiceId)
+	get_resp, _ := ServiceVersionApi.ListServiceVersions(invalidServiceId, models.ListOptions{})
 	assert.Equal(t, 200, get_resp.StatusCode)
 	serviceVersions := listServiceVersionsAndExtractTheList(invalidServiceId)
 	assert.Nil(t, serviceVersions.Items)
@@ -240,12 +241,12 @@ func TestServiceVersionApi_DeleteServiceVersion_VerifyListServiceVersionSize(t *
 }
 
 /*
-Delete the service  and verify that the linked service versions remains unaffected
+Delete the service with cascade and verify that the linked service versions are deleted along with it
 1. Create Service and service version
 2. Verify GET service versions is successful
-3. Delete Service
+3. Delete Service with cascade
 
-There is no impact on service version with the service deleted
+The service versions are no longer listed with the service deleted
 */
 func TestServiceVersionApi_DeleteService_VerifyListServiceVersion(t *testing.T) {
 
@@ -258,7 +259,7 @@ func TestServiceVersionApi_DeleteService_VerifyListServiceVersion(t *testing.T)
 
 	//Delete Service by Id and check the object is deleted completely
 
-	delete_response, _ := ServiceApi.DeleteService(serviceId)
+	delete_response, _ := ServiceApi.DeleteServiceCascade(serviceId)
 	assert.Equal(t, 204, delete_response.StatusCode)
 	assert.True(t, delete_response.ContentLength == 0)
 
@@ -296,8 +297,8 @@ func TestServiceVersionApi_DeleteServiceVersion_VerifyGetServiceVersionSize(t *t
 	get_resp_after_delete, _ := ServiceVersionApi.GetServiceVersion(serviceId, versionId)
 	assert.Equal(t, 404, get_resp_after_delete.StatusCode)
 	error_resp := extractErrorResponse(get_resp_after_delete)
-	framework.Logger.Info("Get Service after delete error : " + error_resp.Error)
-	assert.Equal(t, "Service version not found", error_resp.Error)
+	framework.Logger.Info("Get Service after delete error : " + error_resp.Error.Message)
+	assert.Equal(t, "service_version_not_found", error_resp.Error.Code)
 }
 
 /*
@@ -406,6 +407,9 @@ func TestServiceVersionApi_UpdateServiceVersion_Version_MoreThan16Chars(t *testi
 	update_response, _ := ServiceVersionApi.UpdateServiceVersion(serviceId, versionId, patchPayload)
 	assert.NotEqual(t, 500, update_response.StatusCode)
 	assert.Equal(t, 400, update_response.StatusCode)
+	error_resp := extractErrorResponse(update_response)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, []models.FieldError{{Field: "version", Message: "maximum string length is 16"}}, error_resp.Error.Details)
 
 }
 
@@ -443,7 +447,7 @@ func TestServiceVersionApi_UpdateServiceVersion_Fails_With_InvalidServiceId_InUR
 	update_response, _ := ServiceVersionApi.UpdateServiceVersion(invalidServiceId, versionId, patchPayload)
 	assert.Equal(t, 404, update_response.StatusCode)
 	error_resp := extractErrorResponse(update_response)
-	assert.Equal(t, "Service version not found", error_resp.Error)
+	assert.Equal(t, "service_version_not_found", error_resp.Error.Code)
 }
 
 /*
@@ -463,5 +467,120 @@ func TestServiceVersionApi_UpdateServiceVersion_Fails_With_InvalidVersionID_InUR
 	update_response, _ := ServiceVersionApi.UpdateServiceVersion(serviceVersion.Item.ServiceID, invalidVersionId, patchPayload)
 	assert.Equal(t, 404, update_response.StatusCode)
 	error_resp := extractErrorResponse(update_response)
-	assert.Equal(t, "Service version not found", error_resp.Error)
+	assert.Equal(t, "service_version_not_found", error_resp.Error.Code)
+}
+
+/*
+List Service versions with a small limit and verify the pagination metadata on each page
+GET v1/services/{serviceId}/versions?page=&limit=
+*/
+func TestServiceVersionApi_ListServiceVersions_Pagination(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0", "v1.1", "v2.0")
+
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Page: 1, Limit: 2})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	firstPage := extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, 2, len(firstPage.Items))
+	assert.Equal(t, 3, firstPage.Pagination.Total)
+	assert.Equal(t, 2, firstPage.Pagination.TotalPages)
+	assert.Nil(t, firstPage.Pagination.PrevPage)
+	assert.NotNil(t, firstPage.Pagination.NextPage)
+
+	list_resp, _ = ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Page: 2, Limit: 2})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	secondPage := extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, 1, len(secondPage.Items))
+	assert.Nil(t, secondPage.Pagination.NextPage)
+	assert.NotNil(t, secondPage.Pagination.PrevPage)
+	for _, version := range firstPage.Items {
+		exists, _ := serviceVersionWithIDExists(secondPage, version.ID)
+		assert.False(t, exists, "version %v returned on more than one page", version.ID)
+	}
+}
+
+/*
+List Service versions without sort parameters and verify they are ordered by creation time
+*/
+func TestServiceVersionApi_ListServiceVersions_DefaultOrderIsCreatedAt(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v3.0")
+	time.Sleep(1 * time.Second)
+	payload := framework.CreateServiceVersionPayload(serviceId, "", "v1.0")
+	ServiceVersionApi.CreateServiceVersion(serviceId, payload)
+
+	versions := listServiceVersionsAndExtractTheList(serviceId)
+	assert.Equal(t, 2, len(versions.Items))
+	assert.Equal(t, "v3.0", versions.Items[0].Version)
+	assert.Equal(t, "v1.0", versions.Items[1].Version)
+}
+
+/*
+List Service versions sorted by version in both directions
+GET v1/services/{serviceId}/versions?sort=version&order=
+*/
+func TestServiceVersionApi_ListServiceVersions_SortByVersion(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v2.0", "v1.0", "v3.0")
+
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Sort: "version", Order: "asc"})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions := extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, []string{"v1.0", "v2.0", "v3.0"}, versionStrings(versions))
+
+	list_resp, _ = ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Sort: "version", Order: "desc"})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions = extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, []string{"v3.0", "v2.0", "v1.0"}, versionStrings(versions))
+}
+
+/*
+List Service versions filtered by a version prefix
+GET v1/services/{serviceId}/versions?version=
+*/
+func TestServiceVersionApi_ListServiceVersions_FilterByVersionPrefix(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0", "v1.1-beta", "v2.0", "v10_0")
+
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Version: "v1.", Sort: "version"})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions := extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, []string{"v1.0", "v1.1-beta"}, versionStrings(versions))
+	assert.Equal(t, 2, versions.Pagination.Total)
+
+	//LIKE wildcards in the prefix are matched literally
+	list_resp, _ = ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{Version: "v1_"})
+	versions = extractListServiceVersionsResponse(list_resp)
+	assert.Empty(t, versions.Items)
+}
+
+/*
+List Service versions with unknown sort field or order and expect a Bad Request
+*/
+func TestServiceVersionApi_ListServiceVersions_InvalidSortParameters(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0")
+	invalidOptions := []struct {
+		field string
+		opts  models.ListOptions
+	}{
+		{"sort", models.ListOptions{Sort: "id"}},
+		{"order", models.ListOptions{Sort: "version", Order: "sideways"}},
+	}
+	for _, invalid := range invalidOptions {
+		list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, invalid.opts)
+		assert.Equal(t, 400, list_resp.StatusCode)
+		error_resp := extractErrorResponse(list_resp)
+		assert.Equal(t, "validation_failed", error_resp.Error.Code)
+		assert.Equal(t, 1, len(error_resp.Error.Details))
+		assert.Equal(t, invalid.field, error_resp.Error.Details[0].Field)
+	}
+}
+
+func versionStrings(versions models.ListServiceVersions) []string {
+	result := []string{}
+	for _, version := range versions.Items {
+		result = append(result, version.Version)
+	}
+	return result
 }
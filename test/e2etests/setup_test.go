@@ -12,12 +12,22 @@ import (
 	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
 )
 
+// baseUrl is the address of the server under test.
+const baseUrl = "http://localhost:18080"
+
 var (
 	Client            *framework.HttpClient
 	AuthorizationApi  *service.TokensService
 	Configuration     config.Config
 	ServiceApi        *service.ServiceApi
 	ServiceVersionApi *service.ServiceVersionApi
+	SearchApi         *service.SearchApi
+	UserApi           *service.UserApi
+	ApiKeyApi         *service.ApiKeyApi
+	FaultApi          *service.FaultApi
+	AuditApi          *service.AuditApi
+	CatalogApi        *service.CatalogApi
+	IdP               *framework.IdP
 	token             string
 )
 
@@ -27,13 +37,26 @@ func Initialize() {
 	framework.Logger.Info("Setting up tests...")
 
 	// Common setup
-	baseUrl := "http://localhost:18080"
 	Client = framework.NewHttpClient(baseUrl)
+	framework.JwksUrl = baseUrl + "/.well-known/jwks.json"
 	AuthorizationApi = service.NewTokensService(Client, baseUrl)
 	Configuration = framework.GetConfiguration()
 	token = GetToken()
 	ServiceApi = service.NewServiceApi(Client, baseUrl, token)
 	ServiceVersionApi = service.NewServiceVersionApi(Client, baseUrl, token)
+	SearchApi = service.NewSearchApi(Client, baseUrl, token)
+	UserApi = service.NewUserApi(Client, baseUrl, token)
+	ApiKeyApi = service.NewApiKeyApi(Client, baseUrl, token)
+	FaultApi = service.NewFaultApi(Client, baseUrl, token)
+	AuditApi = service.NewAuditApi(Client, baseUrl, token)
+	CatalogApi = service.NewCatalogApi(Client, baseUrl, token)
+	if len(Configuration.TrustedIssuers) > 0 {
+		var err error
+		IdP, err = framework.StartIdP(Configuration.TrustedIssuers[0].Issuer)
+		if err != nil {
+			framework.Logger.Error(fmt.Sprintf("Failed to start the identity provider: %v", err))
+		}
+	}
 	err := framework.InitLogger()
 	if err != nil {
 		framework.Logger.Info(fmt.Sprintf("Failed to initialize logger: %v\n", err))
@@ -42,6 +65,9 @@ func Initialize() {
 
 // Teardown cleans up resources after tests.
 func Teardown() {
+	if IdP != nil {
+		IdP.Close()
+	}
 	framework.Logger.Info("Tests finished") // Add any teardown logic here if needed.
 }
 
@@ -92,15 +118,86 @@ func extractListServiceVersionsResponse(service_resp http.Response) models.ListS
 
 }
 
+func extractSearchResponse(search_resp http.Response) models.SearchResults {
+	resp_object, _ := framework.ParseResponseBody[models.SearchResults](search_resp.Body)
+	return resp_object
+
+}
+
+func extractUserResponse(user_resp http.Response) models.UserResponse {
+	resp_object, _ := framework.ParseResponseBody[models.UserResponse](user_resp.Body)
+	return resp_object
+
+}
+
+func extractListUsersResponse(list_resp http.Response) models.ListUsers {
+	resp_object, _ := framework.ParseResponseBody[models.ListUsers](list_resp.Body)
+	return resp_object
+
+}
+
+func extractApiKeyResponse(key_resp http.Response) models.ApiKeyResponse {
+	resp_object, _ := framework.ParseResponseBody[models.ApiKeyResponse](key_resp.Body)
+	return resp_object
+
+}
+
+func extractListApiKeysResponse(list_resp http.Response) models.ListApiKeys {
+	resp_object, _ := framework.ParseResponseBody[models.ListApiKeys](list_resp.Body)
+	return resp_object
+
+}
+
+func extractServiceHistoryResponse(history_resp http.Response) models.ListServiceHistory {
+	resp_object, _ := framework.ParseResponseBody[models.ListServiceHistory](history_resp.Body)
+	return resp_object
+
+}
+
+func extractCatalogDiffResponse(diff_resp http.Response) models.CatalogDiff {
+	resp_object, _ := framework.ParseResponseBody[models.CatalogDiff](diff_resp.Body)
+	return resp_object
+
+}
+
+func extractListAuditEventsResponse(list_resp http.Response) models.ListAuditEvents {
+	resp_object, _ := framework.ParseResponseBody[models.ListAuditEvents](list_resp.Body)
+	return resp_object
+
+}
+
+// listServicesAndExtractTheList walks every page of GET /v1/services and returns all the services.
 func listServicesAndExtractTheList() models.ListServices {
-	listServices, _ := ServiceApi.ListServices()
-	services := extractListServicesResponse(listServices)
+	var services models.ListServices
+	opts := models.ListOptions{Page: 1, Limit: 100}
+	for {
+		listServices, _ := ServiceApi.ListServices(opts)
+		page := extractListServicesResponse(listServices)
+		services.Items = append(services.Items, page.Items...)
+		services.Pagination = page.Pagination
+		if page.Pagination.NextPage == nil {
+			break
+		}
+		opts.Page = *page.Pagination.NextPage
+	}
 	return services
 }
 
+// listServiceVersionsAndExtractTheList walks every page of GET /v1/services/{serviceId}/versions
+// and returns all the versions of the service.
 func listServiceVersionsAndExtractTheList(serviceId string) models.ListServiceVersions {
-	listServiceVersions, _ := ServiceVersionApi.ListServiceVersions(serviceId)
-	serviceVersionsList := extractListServiceVersionsResponse(listServiceVersions)
+	var serviceVersionsList models.ListServiceVersions
+	opts := models.ListOptions{Page: 1, Limit: 100}
+	for {
+		listServiceVersions, _ := ServiceVersionApi.ListServiceVersions(serviceId, opts)
+		page := extractListServiceVersionsResponse(listServiceVersions)
+		serviceVersionsList.Items = append(serviceVersionsList.Items, page.Items...)
+		serviceVersionsList.Pagination = page.Pagination
+		if page.Pagination.NextPage == nil {
+			break
+		}
+		opts.Page = *page.Pagination.NextPage
+	}
 	return serviceVersionsList
 }
 
@@ -174,3 +271,29 @@ func CreateServiceVersion_Success() models.ServiceVersionResponse {
 	service_version_object := extractServiceVersionResponse(service_version_resp)
 	return service_version_object
 }
+
+// CreateServiceWithVersions creates a new service with the given versions and returns the service ID.
+func CreateServiceWithVersions(versions ...string) string {
+	service_object := CreateService_Success()
+	serviceId := service_object.Item.ID
+	for _, version := range versions {
+		payload := framework.CreateServiceVersionPayload(serviceId, "", version)
+		service_version_resp, _ := ServiceVersionApi.CreateServiceVersion(serviceId, payload)
+		if service_version_resp.StatusCode != 201 {
+			framework.Logger.Error(fmt.Sprintf("Error in creating Service version: Status code is %v", service_version_resp.StatusCode))
+		}
+	}
+	return serviceId
+}
+
+// CreateUser creates a user with the given role and a random username and
+// password through the admin token and returns it along with its password.
+func CreateUser(role string) (models.User, string) {
+	password := framework.RandomString(12)
+	payload := models.NewUser{Username: framework.GetRandomName("user"), Password: password, Role: role}
+	user_resp, _ := UserApi.CreateUser(payload)
+	if user_resp.StatusCode != 201 {
+		framework.Logger.Error(fmt.Sprintf("Error in creating User: Status code is %v", user_resp.StatusCode))
+	}
+	return extractUserResponse(user_resp).Item, password
+}
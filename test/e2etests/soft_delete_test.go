@@ -0,0 +1,175 @@
+package e2etests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// findService returns the service with the given ID in a list, or nil
+func findService(services []models.Service, serviceId string) *models.Service {
+	for i := range services {
+		if services[i].ID == serviceId {
+			return &services[i]
+		}
+	}
+	return nil
+}
+
+/*
+A deleted service is hidden until it is restored, and only listed with include_deleted
+*/
+func TestSoftDelete_DeleteService_ThenRestore(t *testing.T) {
+
+	keyword := strings.ToLower(framework.RandomString(12))
+	create_resp, _ := CreateService(framework.CreateServicePayload("", "Restorable "+keyword, ""))
+	assert.Equal(t, 201, create_resp.StatusCode)
+	serviceId := extractServiceResponse(create_resp).Item.ID
+
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	assert.Equal(t, 404, get_resp.StatusCode)
+	assert.Equal(t, 0, countServicesNamed(t, keyword))
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: keyword, IncludeDeleted: true})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	deleted := findService(extractListServicesResponse(list_resp).Items, serviceId)
+	if assert.NotNil(t, deleted) {
+		assert.NotNil(t, deleted.DeletedAt)
+	}
+
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+	assert.NotEmpty(t, restore_resp.Header.Get("ETag"))
+	restored := extractServiceResponse(restore_resp)
+	assert.Equal(t, serviceId, restored.Item.ID)
+	assert.Nil(t, restored.Item.DeletedAt)
+
+	get_resp, _ = ServiceApi.GetService(serviceId)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, 1, countServicesNamed(t, keyword))
+}
+
+/*
+A deleted service version is hidden from the versions of its service until it is restored
+*/
+func TestSoftDelete_DeleteServiceVersion_ThenRestore(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0", "v2.0.0")
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+
+	delete_resp, _ := ServiceVersionApi.DeleteServiceVersion(serviceId, versionId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	get_resp, _ := ServiceVersionApi.GetServiceVersion(serviceId, versionId)
+	assert.Equal(t, 404, get_resp.StatusCode)
+	assert.Len(t, listServiceVersionsAndExtractTheList(serviceId).Items, 1)
+
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{IncludeDeleted: true})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions := extractListServiceVersionsResponse(list_resp)
+	assert.Equal(t, 2, versions.Pagination.Total)
+	assert.Equal(t, versionId, versions.Items[0].ID)
+	assert.NotNil(t, versions.Items[0].DeletedAt)
+
+	restore_resp, _ := ServiceVersionApi.RestoreServiceVersion(serviceId, versionId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+	assert.Equal(t, versionId, extractServiceVersionResponse(restore_resp).Item.ID)
+	assert.Len(t, listServiceVersionsAndExtractTheList(serviceId).Items, 2)
+}
+
+/*
+Deleted services can neither be updated nor deleted again, and their ETag changes once restored
+*/
+func TestSoftDelete_DeletedServiceIsReadOnly(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	get_resp, _ := ServiceApi.GetService(serviceId)
+	etag := get_resp.Header.Get("ETag")
+
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	update_resp, _ := ServiceApi.UpdateService(serviceId, models.Service{Description: "changed"})
+	assert.Equal(t, 404, update_resp.StatusCode)
+	delete_resp, _ = ServiceApi.DeleteServiceIfMatch(serviceId, etag)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+	assert.NotEqual(t, etag, restore_resp.Header.Get("ETag"))
+	assert.Equal(t, "test service", extractServiceResponse(restore_resp).Item.Description)
+}
+
+/*
+Restoring a service that is not deleted leaves it unchanged, and restoring an unknown service fails
+*/
+func TestSoftDelete_RestoreLiveOrUnknownService(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	get_resp, _ := ServiceApi.GetService(serviceId)
+
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 200, restore_resp.StatusCode)
+	assert.Equal(t, get_resp.Header.Get("ETag"), restore_resp.Header.Get("ETag"))
+
+	restore_resp, _ = ServiceApi.RestoreService(uuid.NewString())
+	assert.Equal(t, 404, restore_resp.StatusCode)
+	assert.Equal(t, "service_not_found", extractErrorResponse(restore_resp).Error.Code)
+}
+
+/*
+Viewers cannot restore services
+*/
+func TestSoftDelete_RestoreService_ViewerIsForbidden(t *testing.T) {
+
+	serviceId := CreateService_Success().Item.ID
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	viewer, password := CreateUser("viewer")
+	viewerApi := service.NewServiceApi(Client, baseUrl, login(t, viewer.Username, password).Token)
+	restore_resp, _ := viewerApi.RestoreService(serviceId)
+	assert.Equal(t, 403, restore_resp.StatusCode)
+}
+
+/*
+Deleted services are purged with their versions after the purge horizon, and can no longer be restored.
+The purge is recorded as a single event of the service, made by the system
+*/
+func TestSoftDelete_DeletedServicesArePurged(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	delete_resp, _ := ServiceApi.DeleteServiceCascade(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+
+	// Tombstones are stored with a precision of a second
+	time.Sleep(Configuration.SoftDelete.PurgeAfter + 2*Configuration.SoftDelete.PurgeInterval + time.Second)
+
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 404, restore_resp.StatusCode)
+	restore_resp, _ = ServiceVersionApi.RestoreServiceVersion(serviceId, versionId)
+	assert.Equal(t, 404, restore_resp.StatusCode)
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{IncludeDeleted: true})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	assert.Equal(t, 0, extractListServiceVersionsResponse(list_resp).Pagination.Total)
+
+	events := listAuditEvents(t, map[string]string{"action": "purge", "resource_id": serviceId})
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "service", events[0].ResourceType)
+		assert.Equal(t, "system", events[0].Actor)
+		assert.NotEmpty(t, events[0].RequestID)
+		assert.Equal(t, "null", string(events[0].After))
+		var before models.Service
+		assert.NoError(t, json.Unmarshal(events[0].Before, &before))
+		assert.NotNil(t, before.DeletedAt)
+	}
+	assert.Empty(t, listAuditEvents(t, map[string]string{"action": "purge", "resource_id": versionId}))
+}
@@ -0,0 +1,255 @@
+package e2etests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// instant returns the current time, in between changes made before and after it
+func instant() string {
+	time.Sleep(50 * time.Millisecond)
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(50 * time.Millisecond)
+	return now
+}
+
+// changesOf returns the changes of a catalog diff that concern the given service
+func changesOf(diff models.CatalogDiff, serviceId string) []models.CatalogChange {
+	var changes []models.CatalogChange
+	for _, change := range diff.Items {
+		if change.ServiceID == serviceId {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+/*
+A service is read as it was at a past time, and is not found before it was created or after it was deleted
+*/
+func TestTemporal_GetServiceAsOf(t *testing.T) {
+
+	beforeCreation := instant()
+	serviceId := CreateService_Success().Item.ID
+	created := instant()
+	update_resp, _ := ServiceApi.UpdateService(serviceId, models.Service{Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	updated := instant()
+	delete_resp, _ := ServiceApi.DeleteService(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	deleted := instant()
+
+	get_resp, _ := ServiceApi.GetServiceAsOf(serviceId, beforeCreation)
+	assert.Equal(t, 404, get_resp.StatusCode)
+	get_resp, _ = ServiceApi.GetServiceAsOf(serviceId, created)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, `"1"`, get_resp.Header.Get("ETag"))
+	assert.Equal(t, "test service", extractServiceResponse(get_resp).Item.Description)
+	get_resp, _ = ServiceApi.GetServiceAsOf(serviceId, updated)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, "changed", extractServiceResponse(get_resp).Item.Description)
+	get_resp, _ = ServiceApi.GetServiceAsOf(serviceId, deleted)
+	assert.Equal(t, 404, get_resp.StatusCode)
+
+	assert.NotNil(t, findService(listServicesAsOf(t, updated, false), serviceId))
+	assert.Nil(t, findService(listServicesAsOf(t, deleted, false), serviceId))
+	if past := findService(listServicesAsOf(t, deleted, true), serviceId); assert.NotNil(t, past) {
+		assert.NotNil(t, past.DeletedAt)
+		assert.Equal(t, "changed", past.Description)
+	}
+}
+
+// listServicesAsOf walks every page of GET /v1/services as of a time and returns all the services
+func listServicesAsOf(t *testing.T, asOf string, includeDeleted bool) []models.Service {
+	var services []models.Service
+	opts := models.ListOptions{Page: 1, Limit: 100, AsOf: asOf, IncludeDeleted: includeDeleted}
+	for {
+		list_resp, _ := ServiceApi.ListServices(opts)
+		assert.Equal(t, 200, list_resp.StatusCode)
+		page := extractListServicesResponse(list_resp)
+		services = append(services, page.Items...)
+		if opts.Page >= page.Pagination.TotalPages {
+			return services
+		}
+		opts.Page++
+	}
+}
+
+/*
+Service versions are read as they were at a past time, under the ID they had then
+*/
+func TestTemporal_ServiceVersionsAsOf(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	created := instant()
+	update_resp, _ := ServiceVersionApi.UpdateServiceVersion(serviceId, versionId, models.ServiceVersion{Version: "v2.0.0"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	updated := instant()
+
+	get_resp, _ := ServiceVersionApi.GetServiceVersionAsOf(serviceId, versionId, created)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, "v1.0.0", extractServiceVersionResponse(get_resp).Item.Version)
+	get_resp, _ = ServiceVersionApi.GetServiceVersionAsOf(serviceId, versionId, updated)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, "v2.0.0", extractServiceVersionResponse(get_resp).Item.Version)
+
+	list_resp, _ := ServiceVersionApi.ListServiceVersions(serviceId, models.ListOptions{AsOf: created})
+	assert.Equal(t, 200, list_resp.StatusCode)
+	versions := extractListServiceVersionsResponse(list_resp)
+	if assert.Len(t, versions.Items, 1) {
+		assert.Equal(t, versionId, versions.Items[0].ID)
+		assert.Equal(t, "v1.0.0", versions.Items[0].Version)
+	}
+}
+
+/*
+The diff of the catalog between two instants lists the services and versions added, removed and modified
+*/
+func TestTemporal_CatalogDiff(t *testing.T) {
+
+	modifiedId := CreateService_Success().Item.ID
+	removedId := CreateService_Success().Item.ID
+	from := instant()
+	addedId := CreateServiceWithVersions("v1.0.0")
+	update_resp, _ := ServiceApi.UpdateService(modifiedId, models.Service{Description: "changed"})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	delete_resp, _ := ServiceApi.DeleteService(removedId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	to := instant()
+
+	diff_resp, _ := CatalogApi.DiffCatalog(from, to, models.ListOptions{})
+	assert.Equal(t, 200, diff_resp.StatusCode)
+	diff := extractCatalogDiffResponse(diff_resp)
+	assert.Equal(t, map[string]int{"added": 2, "removed": 1, "modified": 1}, diff.Summary)
+
+	added := changesOf(diff, addedId)
+	if assert.Len(t, added, 2) {
+		assert.Equal(t, "service", added[0].ResourceType)
+		assert.Equal(t, "added", added[0].Change)
+		assert.Equal(t, "service_version", added[1].ResourceType)
+		assert.Equal(t, "added", added[1].Change)
+	}
+	modified := changesOf(diff, modifiedId)
+	if assert.Len(t, modified, 1) {
+		assert.Equal(t, "modified", modified[0].Change)
+		assert.Equal(t, []models.FieldChange{{Field: "description", From: "test service", To: "changed"}},
+			modified[0].Changes)
+	}
+	removed := changesOf(diff, removedId)
+	if assert.Len(t, removed, 1) {
+		assert.Equal(t, "removed", removed[0].Change)
+		assert.Equal(t, removedId, removed[0].ResourceID)
+	}
+
+	// Nothing changed after the later instant
+	diff_resp, _ = CatalogApi.DiffCatalog(to, to, models.ListOptions{})
+	assert.Equal(t, 200, diff_resp.StatusCode)
+	assert.Empty(t, extractCatalogDiffResponse(diff_resp).Items)
+}
+
+/*
+The diff of the catalog is paginated, services first, with the summary of all the pages
+*/
+func TestTemporal_CatalogDiffPagination(t *testing.T) {
+
+	from := instant()
+	firstId := CreateServiceWithVersions("v1.0.0")
+	secondId := CreateService_Success().Item.ID
+	to := instant()
+
+	diff_resp, _ := CatalogApi.DiffCatalog(from, to, models.ListOptions{Page: 2, Limit: 1})
+	assert.Equal(t, 200, diff_resp.StatusCode)
+	diff := extractCatalogDiffResponse(diff_resp)
+	assert.Equal(t, map[string]int{"added": 3, "removed": 0, "modified": 0}, diff.Summary)
+	assert.Equal(t, 3, diff.Pagination.Total)
+	if assert.Len(t, diff.Items, 1) {
+		assert.Equal(t, "service", diff.Items[0].ResourceType)
+		assert.Equal(t, secondId, diff.Items[0].ResourceID)
+	}
+
+	diff_resp, _ = CatalogApi.DiffCatalog(from, to, models.ListOptions{Page: 2, Limit: 2})
+	assert.Equal(t, 200, diff_resp.StatusCode)
+	diff = extractCatalogDiffResponse(diff_resp)
+	if assert.Len(t, diff.Items, 1) {
+		assert.Equal(t, "service_version", diff.Items[0].ResourceType)
+		assert.Equal(t, firstId, diff.Items[0].ServiceID)
+	}
+}
+
+/*
+A purged service and its versions are still read as they were before their deletion, and are removed in the diff
+across the deletion, apart from the services created after the purge
+*/
+func TestTemporal_PurgedServicesKeepTheirPast(t *testing.T) {
+
+	serviceId := CreateServiceWithVersions("v1.0.0")
+	versionId := listServiceVersionsAndExtractTheList(serviceId).Items[0].ID
+	created := instant()
+	delete_resp, _ := ServiceApi.DeleteServiceCascade(serviceId)
+	assert.Equal(t, 204, delete_resp.StatusCode)
+	deleted := instant()
+
+	// Tombstones are stored with a precision of a second
+	time.Sleep(Configuration.SoftDelete.PurgeAfter + 2*Configuration.SoftDelete.PurgeInterval + time.Second)
+	restore_resp, _ := ServiceApi.RestoreService(serviceId)
+	assert.Equal(t, 404, restore_resp.StatusCode)
+	addedId := CreateService_Success().Item.ID
+	purged := instant()
+
+	get_resp, _ := ServiceApi.GetServiceAsOf(serviceId, created)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, serviceId, extractServiceResponse(get_resp).Item.ID)
+	get_resp, _ = ServiceVersionApi.GetServiceVersionAsOf(serviceId, versionId, created)
+	assert.Equal(t, 200, get_resp.StatusCode)
+	assert.Equal(t, "v1.0.0", extractServiceVersionResponse(get_resp).Item.Version)
+	get_resp, _ = ServiceApi.GetServiceAsOf(serviceId, deleted)
+	assert.Equal(t, 404, get_resp.StatusCode)
+
+	// Deleted until the purge, and gone after it
+	if past := findService(listServicesAsOf(t, deleted, true), serviceId); assert.NotNil(t, past) {
+		assert.NotNil(t, past.DeletedAt)
+	}
+	assert.Nil(t, findService(listServicesAsOf(t, purged, true), serviceId))
+
+	diff_resp, _ := CatalogApi.DiffCatalog(created, purged, models.ListOptions{})
+	assert.Equal(t, 200, diff_resp.StatusCode)
+	diff := extractCatalogDiffResponse(diff_resp)
+	removed := changesOf(diff, serviceId)
+	if assert.Len(t, removed, 2) {
+		assert.Equal(t, "service", removed[0].ResourceType)
+		assert.Equal(t, "removed", removed[0].Change)
+		assert.Equal(t, "service_version", removed[1].ResourceType)
+		assert.Equal(t, "removed", removed[1].Change)
+	}
+	added := changesOf(diff, addedId)
+	if assert.Len(t, added, 1) {
+		assert.Equal(t, "added", added[0].Change)
+	}
+}
+
+/*
+Instants must be valid date-times, in order, and past services cannot be searched
+*/
+func TestTemporal_InvalidInstants(t *testing.T) {
+
+	now := time.Now().UTC()
+	diff_resp, _ := CatalogApi.DiffCatalog(now.Format(time.RFC3339), now.Add(-time.Hour).Format(time.RFC3339),
+		models.ListOptions{})
+	assert.Equal(t, 400, diff_resp.StatusCode)
+	assert.Equal(t, "validation_failed", extractErrorResponse(diff_resp).Error.Code)
+	diff_resp, _ = CatalogApi.DiffCatalog(now.Format(time.RFC3339), "", models.ListOptions{})
+	assert.Equal(t, 400, diff_resp.StatusCode)
+
+	get_resp, _ := ServiceApi.GetServiceAsOf(CreateService_Success().Item.ID, "yesterday")
+	assert.Equal(t, 400, get_resp.StatusCode)
+	assert.Equal(t, "validation_failed", extractErrorResponse(get_resp).Error.Code)
+
+	list_resp, _ := ServiceApi.ListServices(models.ListOptions{Query: framework.RandomString(8),
+		AsOf: now.Format(time.RFC3339)})
+	assert.Equal(t, 400, list_resp.StatusCode)
+}
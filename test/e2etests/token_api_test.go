@@ -16,7 +16,7 @@ func TestAuthService_CreateToken_InvalidUsername(t *testing.T) {
 	assert.Equal(t, 401, resp.StatusCode)
 	assert.Nil(t, err.Error)
 	errorBody, _ := framework.ParseResponseBody[models.ErrorResponse](resp.Body)
-	assert.Equal(t, "Invalid username or password", errorBody.Error)
+	assert.Equal(t, "invalid_credentials", errorBody.Error.Code)
 }
 
 func TestAuthService_CreateToken_InvalidPassword(t *testing.T) {
@@ -26,7 +26,9 @@ func TestAuthService_CreateToken_InvalidPassword(t *testing.T) {
 	assert.Equal(t, 401, resp.StatusCode)
 	assert.Nil(t, err.Error)
 	errorBody, _ := framework.ParseResponseBody[models.ErrorResponse](resp.Body)
-	assert.Contains(t, errorBody.Error, "password is not equal to ")
+	assert.Equal(t, "invalid_credentials", errorBody.Error.Code)
+	assert.Equal(t, "Invalid username or password", errorBody.Error.Message)
+	assert.NotContains(t, errorBody.Error.Message, Configuration.Password)
 }
 
 func TestAuthService_CreateToken_EmptyUsername(t *testing.T) {
@@ -36,7 +38,7 @@ func TestAuthService_CreateToken_EmptyUsername(t *testing.T) {
 	assert.Equal(t, 401, resp.StatusCode)
 	assert.Nil(t, err.Error)
 	errorBody, _ := framework.ParseResponseBody[models.ErrorResponse](resp.Body)
-	assert.Equal(t, "Invalid username or password", errorBody.Error)
+	assert.Equal(t, "invalid_credentials", errorBody.Error.Code)
 }
 
 func TestAuthService_CreateToken_EmptyPassword(t *testing.T) {
@@ -46,7 +48,9 @@ func TestAuthService_CreateToken_EmptyPassword(t *testing.T) {
 	assert.Equal(t, 401, resp.StatusCode)
 	assert.Nil(t, err.Error)
 	errorBody, _ := framework.ParseResponseBody[models.ErrorResponse](resp.Body)
-	assert.Contains(t, errorBody.Error, "password is not equal to ")
+	assert.Equal(t, "invalid_credentials", errorBody.Error.Code)
+	assert.Equal(t, "Invalid username or password", errorBody.Error.Message)
+	assert.NotContains(t, errorBody.Error.Message, Configuration.Password)
 }
 
 func TestAuthService_CreateToken_CheckTokenValidity(t *testing.T) {
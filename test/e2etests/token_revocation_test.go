@@ -0,0 +1,151 @@
+package e2etests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// login creates a token pair for the given credentials
+func login(t *testing.T, username string, password string) server.TokenResponse {
+	resp, _ := AuthorizationApi.CreateToken(framework.CreateCredentialsReqBody(username, password))
+	assert.Equal(t, 200, resp.StatusCode)
+	tokens, _ := framework.ParseResponseBody[server.TokenResponse](resp.Body)
+	return tokens
+}
+
+// listServicesWithToken lists the services authenticated with the given token
+func listServicesWithToken(authToken string) http.Response {
+	resp, _ := service.NewServiceApi(Client, baseUrl, authToken).ListServices(models.ListOptions{})
+	return resp
+}
+
+/*
+Exchange a refresh token for a new token pair and use the new access token
+*/
+func TestTokenRefresh_RotatesTokens(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	tokens := login(t, user.Username, password)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 200, resp.StatusCode)
+	refreshed, _ := framework.ParseResponseBody[server.TokenResponse](resp.Body)
+	assert.NotEqual(t, tokens.Token, refreshed.Token)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+	hasRole, _ := framework.TokenHasRoleClaim(refreshed.Token, "viewer")
+	assert.True(t, hasRole)
+
+	list_resp := listServicesWithToken(refreshed.Token)
+	assert.Equal(t, 200, list_resp.StatusCode)
+}
+
+/*
+Refresh with an unknown refresh token and expect a 401
+*/
+func TestTokenRefresh_UnknownToken_IsRejected(t *testing.T) {
+
+	resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: framework.RandomString(43)})
+	assert.Equal(t, 401, resp.StatusCode)
+	error_resp := extractErrorResponse(resp)
+	assert.Equal(t, "invalid_refresh_token", error_resp.Error.Code)
+}
+
+/*
+Reuse a refresh token that was already exchanged; the whole family is revoked, including the token it was exchanged for
+*/
+func TestTokenRefresh_ReusedToken_RevokesFamily(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	tokens := login(t, user.Username, password)
+
+	resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 200, resp.StatusCode)
+	refreshed, _ := framework.ParseResponseBody[server.TokenResponse](resp.Body)
+
+	reuse_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 401, reuse_resp.StatusCode)
+	assert.Equal(t, "invalid_refresh_token", extractErrorResponse(reuse_resp).Error.Code)
+
+	family_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, 401, family_resp.StatusCode)
+}
+
+/*
+Log out by revoking the access token used to authenticate; the token and the refresh token of its login are rejected afterwards
+*/
+func TestTokenRevoke_Logout_RejectsAccessToken(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	tokens := login(t, user.Username, password)
+
+	resp, _ := AuthorizationApi.RevokeToken(tokens.Token, server.RevokeRequest{})
+	assert.Equal(t, 204, resp.StatusCode)
+
+	list_resp := listServicesWithToken(tokens.Token)
+	assert.Equal(t, 401, list_resp.StatusCode)
+	assert.Equal(t, "unauthorized", extractErrorResponse(list_resp).Error.Code)
+
+	// The session ends: the refresh token of the login is revoked as well
+	refresh_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 401, refresh_resp.StatusCode)
+
+	other := login(t, user.Username, password)
+	other_resp := listServicesWithToken(other.Token)
+	assert.Equal(t, 200, other_resp.StatusCode)
+}
+
+/*
+Revoke a refresh token; it can no longer be exchanged for a new token pair
+*/
+func TestTokenRevoke_RefreshToken_CannotBeUsed(t *testing.T) {
+
+	user, password := CreateUser("viewer")
+	tokens := login(t, user.Username, password)
+
+	resp, _ := AuthorizationApi.RevokeToken(tokens.Token, server.RevokeRequest{Token: tokens.RefreshToken})
+	assert.Equal(t, 204, resp.StatusCode)
+
+	refresh_resp, _ := AuthorizationApi.RefreshToken(server.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, 401, refresh_resp.StatusCode)
+}
+
+/*
+Revoke the token of another user: forbidden for a viewer, allowed for an admin
+*/
+func TestTokenRevoke_TokenOfAnotherUser_RequiresAdmin(t *testing.T) {
+
+	victim, victimPassword := CreateUser("viewer")
+	victimTokens := login(t, victim.Username, victimPassword)
+	attacker, attackerPassword := CreateUser("viewer")
+	attackerTokens := login(t, attacker.Username, attackerPassword)
+
+	resp, _ := AuthorizationApi.RevokeToken(attackerTokens.Token, server.RevokeRequest{Token: victimTokens.Token})
+	assert.Equal(t, 403, resp.StatusCode)
+	assert.Equal(t, "forbidden", extractErrorResponse(resp).Error.Code)
+	list_resp := listServicesWithToken(victimTokens.Token)
+	assert.Equal(t, 200, list_resp.StatusCode)
+
+	admin_resp, _ := AuthorizationApi.RevokeToken(GetToken(), server.RevokeRequest{Token: victimTokens.Token})
+	assert.Equal(t, 204, admin_resp.StatusCode)
+	revoked_resp := listServicesWithToken(victimTokens.Token)
+	assert.Equal(t, 401, revoked_resp.StatusCode)
+}
+
+/*
+Revoke a value that is not a token issued by the server and expect a 400
+*/
+func TestTokenRevoke_InvalidToken(t *testing.T) {
+
+	resp, _ := AuthorizationApi.RevokeToken(GetToken(), server.RevokeRequest{Token: "not-a-token"})
+	assert.Equal(t, 400, resp.StatusCode)
+	error_resp := extractErrorResponse(resp)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, "token", error_resp.Error.Details[0].Field)
+}
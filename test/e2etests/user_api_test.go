@@ -0,0 +1,168 @@
+package e2etests
+
+import (
+	"testing"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+	service "github.com/kong/candidate-take-home-exercise-sdet/test/services"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Create a user as the admin and verify
+1. The user is created without exposing its password
+2. The user can request a token with its own credentials
+3. The token is accepted on the catalog endpoints
+POST v1/users
+*/
+func TestUserApi_CreateUser_CanLogIn(t *testing.T) {
+
+	user, password := CreateUser("")
+	assert.NotEmpty(t, user.ID)
+	assert.Equal(t, "viewer", user.Role)
+	assert.False(t, user.Disabled)
+
+	userToken := AuthorizationApi.FetchToken(user.Username, password)
+	tokenIsValid, _ := framework.TokenHasUsernameClaim(userToken, user.Username)
+	assert.True(t, tokenIsValid)
+
+	list_resp, _ := service.NewServiceApi(Client, baseUrl, userToken).ListServices(models.ListOptions{})
+	assert.Equal(t, 200, list_resp.StatusCode)
+}
+
+/*
+Create a user with a username that is already taken and expect a Conflict
+*/
+func TestUserApi_CreateUser_DuplicateUsername(t *testing.T) {
+
+	user, _ := CreateUser("")
+	payload := models.NewUser{Username: user.Username, Password: framework.RandomString(12)}
+	user_resp, _ := UserApi.CreateUser(payload)
+	assert.Equal(t, 409, user_resp.StatusCode)
+	error_resp := extractErrorResponse(user_resp)
+	assert.Equal(t, "user_already_exists", error_resp.Error.Code)
+}
+
+/*
+Create a user with a password that is too short and expect a Bad Request naming the password field
+*/
+func TestUserApi_CreateUser_ShortPassword(t *testing.T) {
+
+	payload := models.NewUser{Username: framework.GetRandomName("user"), Password: "short"}
+	user_resp, _ := UserApi.CreateUser(payload)
+	assert.Equal(t, 400, user_resp.StatusCode)
+	error_resp := extractErrorResponse(user_resp)
+	assert.Equal(t, "validation_failed", error_resp.Error.Code)
+	assert.Equal(t, "password", error_resp.Error.Details[0].Field)
+}
+
+/*
+Create a user named like a user of a trusted issuer, or like the system actor of the audit log, and expect a Bad Request
+naming the username field
+*/
+func TestUserApi_CreateUser_ReservedUsername(t *testing.T) {
+
+	for _, username := range []string{"issuer|" + framework.GetRandomName("user"), "system"} {
+		payload := models.NewUser{Username: username, Password: framework.RandomString(12)}
+		user_resp, _ := UserApi.CreateUser(payload)
+		assert.Equal(t, 400, user_resp.StatusCode)
+		error_resp := extractErrorResponse(user_resp)
+		assert.Equal(t, "validation_failed", error_resp.Error.Code)
+		assert.Equal(t, "username", error_resp.Error.Details[0].Field)
+	}
+}
+
+/*
+List the users as the admin and verify the bootstrap admin and a created user are listed with their role
+GET v1/users
+*/
+func TestUserApi_ListUsers(t *testing.T) {
+
+	user, _ := CreateUser("")
+	usernames := map[string]string{}
+	for page := 1; ; page++ {
+		list_resp, _ := UserApi.ListUsers(models.ListOptions{Page: page, Limit: 100})
+		assert.Equal(t, 200, list_resp.StatusCode)
+		users := extractListUsersResponse(list_resp)
+		for _, listed := range users.Items {
+			usernames[listed.Username] = listed.Role
+		}
+		if users.Pagination.NextPage == nil {
+			break
+		}
+	}
+	assert.Equal(t, "admin", usernames[Configuration.Username])
+	_, found := usernames[user.Username]
+	assert.True(t, found)
+}
+
+/*
+Invoke the user endpoints with the token of an editor and expect Forbidden
+*/
+func TestUserApi_NonAdmin_IsForbidden(t *testing.T) {
+
+	user, password := CreateUser("editor")
+	userApi := service.NewUserApi(Client, baseUrl, AuthorizationApi.FetchToken(user.Username, password))
+
+	create_resp, _ := userApi.CreateUser(models.NewUser{Username: framework.GetRandomName("user"), Password: framework.RandomString(12)})
+	assert.Equal(t, 403, create_resp.StatusCode)
+	assert.Equal(t, "forbidden", extractErrorResponse(create_resp).Error.Code)
+
+	list_resp, _ := userApi.ListUsers(models.ListOptions{})
+	assert.Equal(t, 403, list_resp.StatusCode)
+
+	disabled := true
+	update_resp, _ := userApi.UpdateUser(user.ID, models.UserUpdate{Disabled: &disabled})
+	assert.Equal(t, 403, update_resp.StatusCode)
+}
+
+/*
+Disable a user and verify
+1. The user can no longer request a token
+2. The tokens issued before it was disabled are rejected
+PATCH v1/users/{userId}
+*/
+func TestUserApi_DisableUser(t *testing.T) {
+
+	user, password := CreateUser("")
+	userToken := AuthorizationApi.FetchToken(user.Username, password)
+
+	disabled := true
+	update_resp, _ := UserApi.UpdateUser(user.ID, models.UserUpdate{Disabled: &disabled})
+	assert.Equal(t, 200, update_resp.StatusCode)
+	assert.True(t, extractUserResponse(update_resp).Item.Disabled)
+
+	token_resp, _ := AuthorizationApi.CreateToken(framework.CreateCredentialsReqBody(user.Username, password))
+	assert.Equal(t, 401, token_resp.StatusCode)
+
+	list_resp, _ := service.NewServiceApi(Client, baseUrl, userToken).ListServices(models.ListOptions{})
+	assert.Equal(t, 401, list_resp.StatusCode)
+}
+
+/*
+Change the password of a user and verify only the new password is accepted
+*/
+func TestUserApi_ChangePassword(t *testing.T) {
+
+	user, oldPassword := CreateUser("")
+	newPassword := framework.RandomString(12)
+	update_resp, _ := UserApi.UpdateUser(user.ID, models.UserUpdate{Password: &newPassword})
+	assert.Equal(t, 200, update_resp.StatusCode)
+
+	token_resp, _ := AuthorizationApi.CreateToken(framework.CreateCredentialsReqBody(user.Username, oldPassword))
+	assert.Equal(t, 401, token_resp.StatusCode)
+	token_resp, _ = AuthorizationApi.CreateToken(framework.CreateCredentialsReqBody(user.Username, newPassword))
+	assert.Equal(t, 200, token_resp.StatusCode)
+}
+
+/*
+Update a user that does not exist and expect a Not Found
+*/
+func TestUserApi_UpdateUser_NonExistentId(t *testing.T) {
+
+	disabled := true
+	update_resp, _ := UserApi.UpdateUser("invalid", models.UserUpdate{Disabled: &disabled})
+	assert.Equal(t, 404, update_resp.StatusCode)
+	assert.Equal(t, "user_not_found", extractErrorResponse(update_resp).Error.Code)
+}
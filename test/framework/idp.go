@@ -0,0 +1,114 @@
+package framework
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
+)
+
+// IdP is a stand-in for an external OIDC identity provider: it signs tokens with its own keys and publishes them at
+// /jwks.json, as configured for a trusted issuer of the server
+type IdP struct {
+	Issuer string
+
+	server  *httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	signing string
+}
+
+// StartIdP serves an identity provider on the host of the issuer URL
+func StartIdP(issuer string) (*IdP, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer %v: %w", issuer, err)
+	}
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %v: %w", u.Host, err)
+	}
+
+	idp := &IdP{Issuer: issuer, keys: map[string]*rsa.PrivateKey{}}
+	if err := idp.RotateKey(); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	idp.server = httptest.NewUnstartedServer(http.HandlerFunc(idp.serveJWKS))
+	idp.server.Listener = listener
+	idp.server.Start()
+	return idp, nil
+}
+
+// Close stops serving the identity provider
+func (idp *IdP) Close() {
+	idp.server.Close()
+}
+
+// RotateKey signs the next tokens with a new key, while still publishing the previous ones
+func (idp *IdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("unable to generate key: %w", err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.signing = GetRandomName("idp-key")
+	idp.keys[idp.signing] = key
+	return nil
+}
+
+// Sign signs claims with the current key of the identity provider
+func (idp *IdP) Sign(claims jwt.MapClaims) (string, error) {
+	idp.mu.Lock()
+	kid, key := idp.signing, idp.keys[idp.signing]
+	idp.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// SignWithUnpublishedKey signs claims with a key the identity provider does not publish
+func (idp *IdP) SignWithUnpublishedKey(claims jwt.MapClaims) (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate key: %w", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = GetRandomName("unpublished-key")
+	return token.SignedString(key)
+}
+
+func (idp *IdP) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/jwks.json" {
+		http.NotFound(w, r)
+		return
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	jwks := server.JWKS{Keys: []server.JWK{}}
+	for kid, key := range idp.keys {
+		jwks.Keys = append(jwks.Keys, server.JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jwks)
+}
@@ -0,0 +1,63 @@
+package framework
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kong/candidate-take-home-exercise-sdet/internal/server"
+)
+
+// JwksUrl is where the server publishes the keys verifying its tokens
+var JwksUrl string
+
+// FetchJWKS downloads the key set published by the server
+func FetchJWKS() (server.JWKS, error) {
+	resp, err := http.Get(JwksUrl)
+	if err != nil {
+		return server.JWKS{}, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	return ParseResponseBody[server.JWKS](resp.Body)
+}
+
+// VerificationKey returns the key verifying a token the way a third party would: tokens with a kid header are
+// verified with the matching key of the JWKS, other tokens with the shared secret of the configuration
+func VerificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return []byte(GetConfiguration().JWTSecret), nil
+	}
+	jwks, err := FetchJWKS()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range jwks.Keys {
+		if key.Kid == kid && key.Alg == token.Method.Alg() {
+			return PublicKeyFromJWK(key)
+		}
+	}
+	return nil, fmt.Errorf("no key %q in the JWKS", kid)
+}
+
+// PublicKeyFromJWK decodes an RSA or EC public key of a JWKS
+func PublicKeyFromJWK(key server.JWK) (interface{}, error) {
+	decode := func(value string) *big.Int {
+		bytes, _ := base64.RawURLEncoding.DecodeString(value)
+		return new(big.Int).SetBytes(bytes)
+	}
+	switch key.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: decode(key.N), E: int(decode(key.E).Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: decode(key.X), Y: decode(key.Y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", key.Kty)
+}
@@ -16,6 +16,7 @@ type Client interface {
 	HttpPost(url string, token string, payload io.Reader) (*http.Response, ApiError)
 	HttpDelete(url string, token string) (*http.Response, ApiError)
 	HttpPatch(url string, token string, payload io.Reader) (*http.Response, ApiError)
+	HttpDo(method string, url string, headers map[string]string, payload io.Reader) (*http.Response, ApiError)
 }
 type ApiError struct {
 	Error    error
@@ -154,6 +155,32 @@ func (httpClient *HttpClient) HttpPatch(path string, token string, payload io.Re
 	return resp, apierror
 }
 
+// HttpDo invokes any method with custom headers, e.g. to authenticate with an API key instead of a token
+func (httpClient *HttpClient) HttpDo(method string, path string, headers map[string]string, payload io.Reader) (*http.Response, ApiError) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, path, payload)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error building the request %v with reason %v ", path, err.Error())
+		Logger.Error(errMsg)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	Logger.Info(fmt.Sprintf("Invoking %v %v ", req.Method, req.URL))
+	resp, err := httpClient.HttpClient.Do(req)
+	apierror := ApiError{err, resp}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error invoking the %v %v with reason %v ", method, path, err.Error())
+		Logger.Error(errMsg)
+	} else {
+		logResponse(resp)
+	}
+
+	return resp, apierror
+}
+
 func logResponse(resp *http.Response) {
 	Logger.Info(fmt.Sprintf("Status Code: %v ", resp.StatusCode))
 
@@ -67,7 +67,7 @@ func StructToReader[T any](s T) (io.Reader, error) {
 }
 
 func GetConfiguration() config.Config {
-	configFile, err := os.Open("../../config.yml")
+	configFile, err := os.Open("../config.yml")
 	if err != nil {
 		Logger.Info(fmt.Sprintf("Error opening config file: %v\n", err))
 		return config.Config{}
@@ -109,10 +109,7 @@ func isJWT(token string) bool {
 
 func TokenHasUsernameClaim(tokenString string, expectedUsername string) (bool, error) {
 	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &models.KongJWTClaim{}, func(token *jwt.Token) (interface{}, error) {
-		// Return the secret key used to sign the token (replace with your actual secret key)
-		return []byte(GetConfiguration().JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &models.KongJWTClaim{}, VerificationKey)
 
 	// Handle errors during parsing
 	if err != nil {
@@ -131,6 +128,33 @@ func TokenHasUsernameClaim(tokenString string, expectedUsername string) (bool, e
 	return false, nil
 }
 
+func TokenHasRoleClaim(tokenString string, expectedRole string) (bool, error) {
+	// Parse the token
+	token, err := jwt.ParseWithClaims(tokenString, &models.KongJWTClaim{}, VerificationKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse token: %v", err)
+	}
+
+	// Check if the token is valid and carries the expected role
+	if claims, ok := token.Claims.(*models.KongJWTClaim); ok && token.Valid {
+		for _, role := range claims.Roles {
+			if role == expectedRole {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ParseToken verifies a token issued by the server and returns its claims
+func ParseToken(tokenString string) (models.KongJWTClaim, error) {
+	claims := models.KongJWTClaim{}
+	if _, err := jwt.ParseWithClaims(tokenString, &claims, VerificationKey); err != nil {
+		return claims, fmt.Errorf("failed to parse token: %v", err)
+	}
+	return claims, nil
+}
+
 func GetRandomNumber() string {
 	rand.Seed(time.Now().UnixNano())         // Seed the random number generator
 	randomNumber := rand.Intn(90000) + 10000 // Ensure the number is 5 digits (10000–99999)
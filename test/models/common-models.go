@@ -2,18 +2,34 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details"`
+	RequestID string       `json:"request_id"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type KongJWTClaim struct {
-	Username string `json:"username"`
-	Expiry   int    `json:"exp"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles"`
+	Expiry   int      `json:"exp"`
+	// SessionID is the refresh token family of the login of the token
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -29,6 +45,8 @@ type Service struct {
 	CreatedAt time.Time `json:"created_at"`
 	// Timestamp when the service was last updated.
 	UpdatedAt time.Time `json:"updated_at"`
+	// Timestamp when the service was deleted, only listed with IncludeDeleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 type NullString struct {
 	sql.NullString
@@ -38,22 +56,201 @@ type ServiceResponse struct {
 	Item Service `json:"item"`
 }
 
+type Pagination struct {
+	Page       int  `json:"page"`
+	Limit      int  `json:"limit"`
+	Total      int  `json:"total"`
+	TotalPages int  `json:"total_pages"`
+	NextPage   *int `json:"next_page"`
+	PrevPage   *int `json:"prev_page"`
+}
+
+// ListOptions holds the query parameters for list endpoints. Zero values are omitted.
+type ListOptions struct {
+	Page    int
+	Limit   int
+	Sort    string
+	Order   string
+	Version string
+	Query   string
+	// IncludeDeleted also lists the deleted resources that can still be restored
+	IncludeDeleted bool
+	// AsOf lists the resources as they were at this RFC3339 time
+	AsOf string
+}
+
 type ListServices struct {
-	Items []Service `json:"items"`
+	Items      []Service  `json:"items"`
+	Pagination Pagination `json:"pagination"`
 }
 
 type ListServiceVersions struct {
-	Items []ServiceVersion `json:"items"`
+	Items      []ServiceVersion `json:"items"`
+	Pagination Pagination       `json:"pagination"`
 }
 
 type ServiceVersion struct {
+	ID        string     `json:"id"`
+	ServiceID string     `json:"service_id"`
+	Version   string     `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type ServiceVersionResponse struct {
+	Item ServiceVersion `json:"item"`
+}
+
+type SearchResult struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	ServiceID  string            `json:"service_id"`
+	Name       string            `json:"name"`
+	Version    string            `json:"version"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type SearchResults struct {
+	Items      []SearchResult `json:"items"`
+	Pagination Pagination     `json:"pagination"`
+}
+
+type User struct {
 	ID        string    `json:"id"`
-	ServiceID string    `json:"service_id"`
-	Version   string    `json:"version"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type ServiceVersionResponse struct {
-	Item ServiceVersion `json:"item"`
+type NewUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+}
+
+// UserUpdate holds the fields of a user to update. Nil fields are omitted.
+type UserUpdate struct {
+	Password *string `json:"password,omitempty"`
+	Role     *string `json:"role,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
+
+type UserResponse struct {
+	Item User `json:"item"`
+}
+
+type ListUsers struct {
+	Items      []User     `json:"items"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type ApiKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Username   string     `json:"username"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type NewApiKey struct {
+	Name string `json:"name"`
+}
+
+// ApiKeyResponse is returned when creating an API key, the only time the key itself is returned.
+type ApiKeyResponse struct {
+	Item ApiKey `json:"item"`
+	Key  string `json:"key"`
+}
+
+type ListApiKeys struct {
+	Items      []ApiKey   `json:"items"`
+	Pagination Pagination `json:"pagination"`
+}
+
+type LoginAttempt struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	Outcome     string    `json:"outcome"`
+	RequestID   string    `json:"request_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+type ListLoginAttempts struct {
+	Items      []LoginAttempt `json:"items"`
+	Pagination Pagination     `json:"pagination"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type HistoryEntry struct {
+	ResourceType string        `json:"resource_type"`
+	ResourceID   string        `json:"resource_id"`
+	Revision     int64         `json:"revision"`
+	ChangedAt    time.Time     `json:"changed_at"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+type ListServiceHistory struct {
+	Items      []HistoryEntry `json:"items"`
+	Pagination Pagination     `json:"pagination"`
+}
+
+type CatalogChange struct {
+	ResourceType string        `json:"resource_type"`
+	ResourceID   string        `json:"resource_id"`
+	ServiceID    string        `json:"service_id"`
+	Change       string        `json:"change"`
+	Changes      []FieldChange `json:"changes"`
+}
+
+type CatalogDiff struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Summary    map[string]int  `json:"summary"`
+	Items      []CatalogChange `json:"items"`
+	Pagination Pagination      `json:"pagination"`
+}
+
+type AuditEvent struct {
+	ID           string          `json:"id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	RequestID    string          `json:"request_id"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type ListAuditEvents struct {
+	Items      []AuditEvent `json:"items"`
+	Pagination Pagination   `json:"pagination"`
+}
+
+type FaultRule struct {
+	Method      string  `json:"method"`
+	Path        string  `json:"path"`
+	Probability float64 `json:"probability"`
+	LatencyMs   int64   `json:"latency_ms"`
+	Status      int     `json:"status"`
+	Hang        bool    `json:"hang"`
+	Drop        bool    `json:"drop"`
+}
+
+type FaultInjection struct {
+	Enabled bool        `json:"enabled"`
+	Seed    int64       `json:"seed"`
+	Rules   []FaultRule `json:"rules,omitempty"`
 }
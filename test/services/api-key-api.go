@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+)
+
+type ApiKeyApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewApiKeyApi(client framework.Client, baseUrl string, token string) *ApiKeyApi {
+	return &ApiKeyApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+func (s *ApiKeyApi) CreateApiKey(req models.NewApiKey) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/api-keys", s.BaseURL)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	apiKeyPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	resp, err := s.Client.HttpPost(url, s.AuthToken, apiKeyPayload)
+
+	return *resp, err
+
+}
+
+func (s *ApiKeyApi) ListApiKeys(opts models.ListOptions) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/api-keys%s", s.BaseURL, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+func (s *ApiKeyApi) RevokeApiKey(keyId string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/api-keys/%s", s.BaseURL, keyId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpDelete(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+// WithApiKey invokes any endpoint authenticated with an API key instead of a token
+func (s *ApiKeyApi) WithApiKey(method string, path string, apiKey string) (http.Response, framework.ApiError) {
+	url := s.BaseURL + path
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpDo(method, url, map[string]string{"X-API-Key": apiKey}, nil)
+
+	return *resp, err
+
+}
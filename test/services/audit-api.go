@@ -0,0 +1,37 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+)
+
+type AuditApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewAuditApi(client framework.Client, baseUrl string, token string) *AuditApi {
+	return &AuditApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+// ListAuditEvents lists the audit log, filtered by the given query parameters (e.g. resource_id, action)
+func (s *AuditApi) ListAuditEvents(filters map[string]string) (http.Response, framework.ApiError) {
+	query := url.Values{}
+	for name, value := range filters {
+		query.Set(name, value)
+	}
+	url := fmt.Sprintf("%s/v1/audit?%s", s.BaseURL, query.Encode())
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
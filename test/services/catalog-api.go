@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+)
+
+type CatalogApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewCatalogApi(client framework.Client, baseUrl string, token string) *CatalogApi {
+	return &CatalogApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+// DiffCatalog summarises the changes of the catalog between two RFC3339 times, a page at a time
+func (s *CatalogApi) DiffCatalog(from string, to string, opts models.ListOptions) (http.Response, framework.ApiError) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	if opts.Page != 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit != 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	url := fmt.Sprintf("%s/v1/catalog/diff?%s", s.BaseURL, query.Encode())
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+)
+
+type FaultApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewFaultApi(client framework.Client, baseUrl string, token string) *FaultApi {
+	return &FaultApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+func (s *FaultApi) GetFaults() (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/admin/faults", s.BaseURL)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+func (s *FaultApi) SetFaults(req models.FaultInjection) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/admin/faults", s.BaseURL)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	faultsPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.AuthToken}
+	resp, err := s.Client.HttpDo(http.MethodPut, url, headers, faultsPayload)
+
+	return *resp, err
+
+}
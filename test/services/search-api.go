@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+)
+
+type SearchApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewSearchApi(client framework.Client, baseUrl string, token string) *SearchApi {
+	return &SearchApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+func (s *SearchApi) Search(query string, opts models.ListOptions) (http.Response, framework.ApiError) {
+	opts.Query = query
+	url := fmt.Sprintf("%s/v1/search%s", s.BaseURL, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
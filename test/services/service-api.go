@@ -3,6 +3,8 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
 	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
@@ -57,6 +59,16 @@ func (s *ServiceApi) GetService(serviceId string) (http.Response, framework.ApiE
 
 }
 
+// GetServiceAsOf gets the service as it was at the given RFC3339 time
+func (s *ServiceApi) GetServiceAsOf(serviceId string, asOf string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s%s", s.BaseURL, serviceId, listQuery(models.ListOptions{AsOf: asOf}))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
 func (s *ServiceApi) DeleteService(serviceId string) (http.Response, framework.ApiError) {
 	url := fmt.Sprintf("%s/v1/services/%s", s.BaseURL, serviceId)
 	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
@@ -66,11 +78,166 @@ func (s *ServiceApi) DeleteService(serviceId string) (http.Response, framework.A
 
 }
 
-func (s *ServiceApi) ListServices() (http.Response, framework.ApiError) {
+func (s *ServiceApi) ListServices(opts models.ListOptions) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services%s", s.BaseURL, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+// listQuery builds the query string for list endpoints, skipping unset options.
+func listQuery(opts models.ListOptions) string {
+	query := url.Values{}
+	if opts.Page != 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit != 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+	if opts.Version != "" {
+		query.Set("version", opts.Version)
+	}
+	if opts.Query != "" {
+		query.Set("q", opts.Query)
+	}
+	if opts.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	if opts.AsOf != "" {
+		query.Set("as_of", opts.AsOf)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// UpdateServiceIfMatch updates the service only if it still has the revision of the given ETag
+func (s *ServiceApi) UpdateServiceIfMatch(serviceId string, req models.Service, etag string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v", s.BaseURL, serviceId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	servicePayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.AuthToken, "If-Match": etag}
+	resp, err := s.Client.HttpDo(http.MethodPatch, url, headers, servicePayload)
+
+	return *resp, err
+
+}
+
+// DeleteServiceIfMatch deletes the service only if it still has the revision of the given ETag
+func (s *ServiceApi) DeleteServiceIfMatch(serviceId string, etag string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s", s.BaseURL, serviceId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken, "If-Match": etag}
+	resp, err := s.Client.HttpDo(http.MethodDelete, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// GetServiceConditionally gets the service with conditional headers, e.g. If-None-Match or If-Modified-Since
+func (s *ServiceApi) GetServiceConditionally(serviceId string, conditions map[string]string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s", s.BaseURL, serviceId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	for name, value := range conditions {
+		headers[name] = value
+	}
+	resp, err := s.Client.HttpDo(http.MethodGet, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// ListServicesConditionally lists the services with conditional headers, e.g. If-None-Match or If-Modified-Since
+func (s *ServiceApi) ListServicesConditionally(opts models.ListOptions, conditions map[string]string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services%s", s.BaseURL, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	for name, value := range conditions {
+		headers[name] = value
+	}
+	resp, err := s.Client.HttpDo(http.MethodGet, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// CreateServiceWithIdempotencyKey creates a service, replaying the first response to the key on retries
+func (s *ServiceApi) CreateServiceWithIdempotencyKey(req models.Service, key string) (http.Response, framework.ApiError) {
 	url := fmt.Sprintf("%s/v1/services", s.BaseURL)
 	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	servicePayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.AuthToken, "Idempotency-Key": key}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, servicePayload)
+
+	return *resp, err
+
+}
+
+// DeleteServiceCascade deletes the service along with its versions
+func (s *ServiceApi) DeleteServiceCascade(serviceId string) (http.Response, framework.ApiError) {
+	return s.DeleteServiceWithCascade(serviceId, "true")
+}
+
+// DeleteServiceWithCascade deletes the service with the given, possibly invalid, cascade query parameter
+func (s *ServiceApi) DeleteServiceWithCascade(serviceId string, cascade string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s?cascade=%s", s.BaseURL, serviceId, url.QueryEscape(cascade))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpDelete(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+// RestoreService restores a deleted service
+func (s *ServiceApi) RestoreService(serviceId string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s:restore", s.BaseURL, serviceId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, nil)
+
+	return *resp, err
+
+}
+
+func (s *ServiceApi) GetServiceHistory(serviceId string, opts models.ListOptions) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s/history%s", s.BaseURL, serviceId, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
 	resp, err := s.Client.HttpGet(url, s.AuthToken)
 
 	return *resp, err
 
 }
+
+func (s *ServiceApi) RevertService(serviceId string, revision int64) (http.Response, framework.ApiError) {
+	return s.RevertServiceIfMatch(serviceId, revision, "")
+}
+
+// RevertServiceIfMatch reverts the service to a prior revision, only if it still has the revision of the given ETag
+func (s *ServiceApi) RevertServiceIfMatch(serviceId string, revision int64, etag string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%s/history/%d:revert", s.BaseURL, serviceId, revision)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	if etag != "" {
+		headers["If-Match"] = etag
+	}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, nil)
+
+	return *resp, err
+
+}
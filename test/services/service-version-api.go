@@ -60,6 +60,17 @@ func (s *ServiceVersionApi) GetServiceVersion(serviceId string, versionId string
 
 }
 
+// GetServiceVersionAsOf gets the service version as it was at the given RFC3339 time
+func (s *ServiceVersionApi) GetServiceVersionAsOf(serviceId string, versionId string, asOf string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions/%v%s", s.BaseURL, serviceId, versionId,
+		listQuery(models.ListOptions{AsOf: asOf}))
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
 func (s *ServiceVersionApi) DeleteServiceVersion(serviceId string, versionId string) (http.Response, framework.ApiError) {
 	url := fmt.Sprintf("%s/v1/services/%v/versions/%v", s.BaseURL, serviceId, versionId)
 	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
@@ -69,11 +80,91 @@ func (s *ServiceVersionApi) DeleteServiceVersion(serviceId string, versionId str
 
 }
 
-func (s *ServiceVersionApi) ListServiceVersions(serviceId string) (http.Response, framework.ApiError) {
-	url := fmt.Sprintf("%s/v1/services/%v/versions", s.BaseURL, serviceId)
+func (s *ServiceVersionApi) ListServiceVersions(serviceId string, opts models.ListOptions) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions%s", s.BaseURL, serviceId, listQuery(opts))
 	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
 	resp, err := s.Client.HttpGet(url, s.AuthToken)
 
 	return *resp, err
 
 }
+
+// UpdateServiceVersionIfMatch updates the service version only if it still has the revision of the given ETag
+func (s *ServiceVersionApi) UpdateServiceVersionIfMatch(serviceId string, versionId string, req models.ServiceVersion, etag string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions/%v", s.BaseURL, serviceId, versionId)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	serviceVersionPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Error(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.AuthToken, "If-Match": etag}
+	resp, err := s.Client.HttpDo(http.MethodPatch, url, headers, serviceVersionPayload)
+
+	return *resp, err
+
+}
+
+// DeleteServiceVersionIfMatch deletes the service version only if it still has the revision of the given ETag
+func (s *ServiceVersionApi) DeleteServiceVersionIfMatch(serviceId string, versionId string, etag string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions/%v", s.BaseURL, serviceId, versionId)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken, "If-Match": etag}
+	resp, err := s.Client.HttpDo(http.MethodDelete, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// GetServiceVersionConditionally gets the service version with conditional headers, e.g. If-None-Match
+func (s *ServiceVersionApi) GetServiceVersionConditionally(serviceId string, versionId string, conditions map[string]string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions/%v", s.BaseURL, serviceId, versionId)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	for name, value := range conditions {
+		headers[name] = value
+	}
+	resp, err := s.Client.HttpDo(http.MethodGet, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// ListServiceVersionsConditionally lists the service versions with conditional headers, e.g. If-None-Match
+func (s *ServiceVersionApi) ListServiceVersionsConditionally(serviceId string, opts models.ListOptions, conditions map[string]string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions%s", s.BaseURL, serviceId, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	for name, value := range conditions {
+		headers[name] = value
+	}
+	resp, err := s.Client.HttpDo(http.MethodGet, url, headers, nil)
+
+	return *resp, err
+
+}
+
+// CreateServiceVersionWithIdempotencyKey creates a service version, replaying the first response to the key on retries
+func (s *ServiceVersionApi) CreateServiceVersionWithIdempotencyKey(serviceId string, req models.ServiceVersion, key string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions", s.BaseURL, serviceId)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	serviceVersionPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Error(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer " + s.AuthToken, "Idempotency-Key": key}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, serviceVersionPayload)
+
+	return *resp, err
+
+}
+
+// RestoreServiceVersion restores a deleted service version
+func (s *ServiceVersionApi) RestoreServiceVersion(serviceId string, versionId string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/services/%v/versions/%v:restore", s.BaseURL, serviceId, versionId)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	headers := map[string]string{"Authorization": "Bearer " + s.AuthToken}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, nil)
+
+	return *resp, err
+
+}
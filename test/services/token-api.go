@@ -36,6 +36,30 @@ func (s *TokensService) CreateToken(req server.Credentials) (http.Response, fram
 
 }
 
+// CreateTokenFrom creates a token on behalf of a client with the given IP address, as forwarded by a proxy
+func (s *TokensService) CreateTokenFrom(req server.Credentials, clientIP string) (http.Response, framework.ApiError) {
+	url := s.BaseURL + "/v1/token"
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	credentialsRequest, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Error(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	headers := map[string]string{"Content-Type": "application/json", "X-Forwarded-For": clientIP}
+	resp, err := s.Client.HttpDo(http.MethodPost, url, headers, credentialsRequest)
+
+	return *resp, err
+
+}
+
+func (s *TokensService) ListLoginAttempts(authToken string, username string) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/login-attempts?username=%s", s.BaseURL, username)
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, authToken)
+
+	return *resp, err
+
+}
+
 func (s *TokensService) FetchToken(username string, password string) string {
 
 	var creds = server.Credentials{Username: username, Password: password}
@@ -44,3 +68,29 @@ func (s *TokensService) FetchToken(username string, password string) string {
 	return token.Token
 
 }
+
+func (s *TokensService) RefreshToken(req server.RefreshRequest) (http.Response, framework.ApiError) {
+	url := s.BaseURL + "/v1/token/refresh"
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	refreshRequest, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Error(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	resp, err := s.Client.HttpPost(url, "", refreshRequest)
+
+	return *resp, err
+
+}
+
+func (s *TokensService) RevokeToken(authToken string, req server.RevokeRequest) (http.Response, framework.ApiError) {
+	url := s.BaseURL + "/v1/token/revoke"
+	framework.Logger.Info(fmt.Sprintf("Request URL " + url))
+	revokeRequest, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Error(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	resp, err := s.Client.HttpPost(url, authToken, revokeRequest)
+
+	return *resp, err
+
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kong/candidate-take-home-exercise-sdet/test/framework"
+	"github.com/kong/candidate-take-home-exercise-sdet/test/models"
+)
+
+type UserApi struct {
+	Client    framework.Client
+	BaseURL   string
+	AuthToken string
+}
+
+func NewUserApi(client framework.Client, baseUrl string, token string) *UserApi {
+	return &UserApi{
+		Client:    client,
+		BaseURL:   baseUrl,
+		AuthToken: token,
+	}
+}
+
+func (s *UserApi) CreateUser(req models.NewUser) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/users", s.BaseURL)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	userPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	resp, err := s.Client.HttpPost(url, s.AuthToken, userPayload)
+
+	return *resp, err
+
+}
+
+func (s *UserApi) ListUsers(opts models.ListOptions) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/users%s", s.BaseURL, listQuery(opts))
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	resp, err := s.Client.HttpGet(url, s.AuthToken)
+
+	return *resp, err
+
+}
+
+func (s *UserApi) UpdateUser(userId string, req models.UserUpdate) (http.Response, framework.ApiError) {
+	url := fmt.Sprintf("%s/v1/users/%s", s.BaseURL, userId)
+	framework.Logger.Info(fmt.Sprintln("Request URL " + url))
+	userPayload, error := framework.StructToReader(req)
+	if error != nil {
+		framework.Logger.Info(fmt.Sprintf("Invalid request payload - %v", error))
+	}
+	resp, err := s.Client.HttpPatch(url, s.AuthToken, userPayload)
+
+	return *resp, err
+
+}